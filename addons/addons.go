@@ -0,0 +1,197 @@
+// Package addons provides the optional components installed into the kind
+// cluster once its pod network is ready. Like the CNI plugins, each add-on
+// registers its own Kubernetes resources through the cluster's provider and
+// supplies the shell script waiting for it to be ready, which expects
+// KUBECONFIG to be exported by the caller, and the health check of its pods.
+// Add-ons that need ports published or nodes labelled also adapt the kind
+// config, which takes effect when the cluster is created, and some run a
+// container on the Docker host next to the nodes.
+package addons
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"myk8s-cluster/healthcheck"
+	"myk8s-cluster/kindconfig"
+	"myk8s-cluster/script"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Addon is an optional cluster component.
+type Addon interface {
+	// Name is the key of the add-on in the `addons` config block.
+	Name() string
+	// KindConfig applies the changes the add-on needs to the kind config of
+	// the cluster, e.g. host ports published by its first node.
+	KindConfig(config *kindconfig.Cluster)
+	// Install registers the Kubernetes resources of the add-on, named after
+	// name, with opts selecting the provider and parent, and returns them.
+	Install(ctx *pulumi.Context, name string, cluster Cluster, opts ...pulumi.ResourceOption) ([]pulumi.Resource, error)
+	// WaitScript blocks until the add-on is ready.
+	WaitScript() string
+	// HealthCheck checks the add-on is running.
+	HealthCheck() healthcheck.Check
+}
+
+// Teardown is implemented by add-ons that leave objects behind which
+// deleting their resources does not remove.
+type Teardown interface {
+	// TeardownScript removes those objects once the resources of the add-on
+	// are deleted. KUBECONFIG is exported when it runs, and the cluster may
+	// be gone already.
+	TeardownScript() string
+}
+
+// Container is implemented by add-ons running a container of their own on
+// the Docker host of the nodes, next to the cluster rather than in it.
+type Container interface {
+	// ContainerScripts start the container once the cluster is created, and
+	// remove it.
+	ContainerScripts() (create, delete string)
+	// HostPorts are the TCP ports the container publishes on 127.0.0.1 of
+	// the Docker host.
+	HostPorts() []int
+}
+
+// Outputs is implemented by add-ons describing how to use them in the stack
+// outputs.
+type Outputs interface {
+	Outputs() map[string]string
+}
+
+// Settings are the entry of an add-on in the `addons` config block. Each
+// add-on reads the settings that apply to it.
+type Settings struct {
+	// Enabled is false to keep an add-on listed without installing it.
+	Enabled *bool `json:"enabled"`
+	// Version is the release, chart or image version, by default the one
+	// this program is tested with.
+	Version string `json:"version"`
+	// HTTPPort and HTTPSPort are the host ports ingress-nginx is published
+	// on, 80 and 443 by default.
+	HTTPPort  int `json:"httpPort"`
+	HTTPSPort int `json:"httpsPort"`
+	// Addresses are the MetalLB address pool, as CIDRs or first-last
+	// ranges, by default x.y.255.200-x.y.255.250 of the node network.
+	Addresses []string `json:"addresses"`
+	// Port is the host port of the registry, 5001 by default.
+	Port int `json:"port"`
+}
+
+// IsEnabled reports whether the add-on is to be installed.
+func (s Settings) IsEnabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+// Options carries the settings of an add-on and what it needs to know about
+// the cluster before it is created.
+type Options struct {
+	Settings
+	// ClusterName is the kind cluster name, which prefixes the node names.
+	ClusterName string
+	// DockerHost is the DOCKER_HOST of the daemon running the nodes, which
+	// the scripts of the add-on export.
+	DockerHost string
+}
+
+// Cluster is what add-ons learn about the cluster once it is up.
+type Cluster struct {
+	// NodeIP is the internal IP of the first node, on the Docker network
+	// of the nodes.
+	NodeIP pulumi.StringOutput
+}
+
+var addons = map[string]func(Options) (Addon, error){
+	"cert-manager":   newCertManager,
+	"ingress-nginx":  newIngressNginx,
+	"metallb":        newMetalLB,
+	"metrics-server": newMetricsServer,
+	"registry":       newRegistry,
+}
+
+// Names lists the supported add-ons.
+func Names() []string {
+	names := make([]string, 0, len(addons))
+	for name := range addons {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns the add-on registered under name, configured with opts.
+func New(name string, opts Options) (Addon, error) {
+	newAddon, ok := addons[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown add-on %q, expected one of %s", name, strings.Join(Names(), ", "))
+	}
+	addon, err := newAddon(opts)
+	if err != nil {
+		return nil, fmt.Errorf("addons.%s: %w", name, err)
+	}
+	return addon, nil
+}
+
+// orDefault returns value, or def if value is empty.
+func orDefault[T comparable](value, def T) T {
+	var zero T
+	if value == zero {
+		return def
+	}
+	return value
+}
+
+// validPort checks a host port setting.
+func validPort(name string, port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("%s %d is out of range", name, port)
+	}
+	return nil
+}
+
+// firstNode returns the node publishing the ports of the cluster, the first
+// control plane.
+func firstNode(config *kindconfig.Cluster) *kindconfig.Node {
+	return &config.Nodes[0]
+}
+
+// after appends a dependency on resources to opts, leaving opts alone.
+func after(opts []pulumi.ResourceOption, resources ...pulumi.Resource) []pulumi.ResourceOption {
+	return append(slices.Clip(opts), pulumi.DependsOn(resources))
+}
+
+// rolloutWait waits for workloads to roll out but, like the rest of the
+// setup, only warns on timeout so the health checks can report the details.
+func rolloutWait(namespace, selector string, workloads ...string) string {
+	return rolloutWaitScript.Render(workloadData{Namespace: namespace, Workloads: workloads, Selector: selector})
+}
+
+// workloadData is what rolloutWaitScript is rendered with.
+type workloadData struct {
+	Namespace string
+	Workloads []string
+	Selector  string
+}
+
+var rolloutWaitScript = script.New("rollout-wait", `
+		for workload in {{quoteAll .Workloads}}; do
+			echo "Waiting for $workload in "{{quote .Namespace}}" to be ready..."
+			if kubectl -n {{quote .Namespace}} rollout status "$workload" --timeout=120s; then
+				echo "$workload is ready!"
+			else
+				echo "Warning: Timed out waiting for $workload to be ready"
+				kubectl -n {{quote .Namespace}} get pods -l {{quote .Selector}}
+			fi
+		done
+	`)
+
+// podsReady is the health check of an add-on running the pods matching
+// selector. An add-on that is down leaves the cluster usable, so it only
+// warns.
+func podsReady(name, namespace, selector string) healthcheck.Check {
+	return healthcheck.PodsReady(name, namespace, selector, healthcheck.Warning)
+}
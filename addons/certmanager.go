@@ -0,0 +1,69 @@
+package addons
+
+import (
+	"myk8s-cluster/healthcheck"
+	"myk8s-cluster/kindconfig"
+
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/helm/v3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// CertManager installs cert-manager and its CRDs with Helm into the
+// cert-manager namespace.
+type CertManager struct {
+	Version string
+}
+
+func newCertManager(o Options) (Addon, error) {
+	return &CertManager{Version: orDefault(o.Version, "v1.16.2")}, nil
+}
+
+func (a *CertManager) Name() string { return "cert-manager" }
+
+func (a *CertManager) KindConfig(*kindconfig.Cluster) {}
+
+func (a *CertManager) Install(ctx *pulumi.Context, name string, _ Cluster, opts ...pulumi.ResourceOption) ([]pulumi.Resource, error) {
+	release, err := helmv3.NewRelease(ctx, name, &helmv3.ReleaseArgs{
+		Name:            pulumi.String("cert-manager"),
+		Chart:           pulumi.String("cert-manager"),
+		Version:         pulumi.String(a.Version),
+		Namespace:       pulumi.String("cert-manager"),
+		CreateNamespace: pulumi.Bool(true),
+		RepositoryOpts: &helmv3.RepositoryOptsArgs{
+			Repo: pulumi.String("https://charts.jetstack.io"),
+		},
+		Values: pulumi.Map{
+			// The CRDs go with the release, and the certificates with them
+			"crds": pulumi.Map{
+				"enabled": pulumi.Bool(true),
+				"keep":    pulumi.Bool(false),
+			},
+		},
+		// WaitScript waits, and only warns if it is slow to come up
+		SkipAwait: pulumi.Bool(true),
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return []pulumi.Resource{release}, nil
+}
+
+func (a *CertManager) WaitScript() string {
+	return rolloutWait("cert-manager", "app.kubernetes.io/instance=cert-manager",
+		"deploy/cert-manager", "deploy/cert-manager-cainjector", "deploy/cert-manager-webhook")
+}
+
+func (a *CertManager) HealthCheck() healthcheck.Check {
+	return podsReady(a.Name(), "cert-manager", "app.kubernetes.io/instance=cert-manager")
+}
+
+// TeardownScript removes the namespace Helm created and the leader
+// election leases cert-manager keeps in kube-system.
+func (a *CertManager) TeardownScript() string {
+	return `
+		echo "Removing what cert-manager left behind..."
+		kubectl delete namespace cert-manager --ignore-not-found --request-timeout=30s 2>/dev/null || true
+		kubectl -n kube-system delete lease cert-manager-controller cert-manager-cainjector-leader-election \
+			--ignore-not-found --request-timeout=30s 2>/dev/null || true
+	`
+}
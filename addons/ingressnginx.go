@@ -0,0 +1,71 @@
+package addons
+
+import (
+	"fmt"
+
+	"myk8s-cluster/healthcheck"
+	"myk8s-cluster/kindconfig"
+
+	yamlv2 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/yaml/v2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// IngressNginx installs the ingress-nginx controller from its manifest for
+// kind, which runs it with host ports on the node labelled ingress-ready.
+// That node is the first control plane, publishing the ports on the host.
+type IngressNginx struct {
+	Version   string
+	HTTPPort  int
+	HTTPSPort int
+}
+
+func newIngressNginx(o Options) (Addon, error) {
+	a := &IngressNginx{
+		Version:   orDefault(o.Version, "v1.12.0"),
+		HTTPPort:  orDefault(o.HTTPPort, 80),
+		HTTPSPort: orDefault(o.HTTPSPort, 443),
+	}
+	if err := validPort("httpPort", a.HTTPPort); err != nil {
+		return nil, err
+	}
+	return a, validPort("httpsPort", a.HTTPSPort)
+}
+
+func (a *IngressNginx) Name() string { return "ingress-nginx" }
+
+func (a *IngressNginx) manifest() string {
+	return fmt.Sprintf("https://raw.githubusercontent.com/kubernetes/ingress-nginx/controller-%s/deploy/static/provider/kind/deploy.yaml", a.Version)
+}
+
+func (a *IngressNginx) KindConfig(config *kindconfig.Cluster) {
+	node := firstNode(config)
+	if node.Labels == nil {
+		node.Labels = map[string]string{}
+	}
+	node.Labels["ingress-ready"] = "true"
+	node.ExtraPortMappings = append(node.ExtraPortMappings,
+		kindconfig.PortMapping{ContainerPort: 80, HostPort: a.HTTPPort, Protocol: "TCP"},
+		kindconfig.PortMapping{ContainerPort: 443, HostPort: a.HTTPSPort, Protocol: "TCP"},
+	)
+}
+
+func (a *IngressNginx) Install(ctx *pulumi.Context, name string, _ Cluster, opts ...pulumi.ResourceOption) ([]pulumi.Resource, error) {
+	manifest, err := yamlv2.NewConfigGroup(ctx, name, &yamlv2.ConfigGroupArgs{
+		Files: pulumi.StringArray{pulumi.String(a.manifest())},
+		// WaitScript waits, and only warns if the controller is slow to
+		// come up
+		SkipAwait: pulumi.Bool(true),
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return []pulumi.Resource{manifest}, nil
+}
+
+func (a *IngressNginx) WaitScript() string {
+	return rolloutWait("ingress-nginx", "app.kubernetes.io/component=controller", "deploy/ingress-nginx-controller")
+}
+
+func (a *IngressNginx) HealthCheck() healthcheck.Check {
+	return podsReady(a.Name(), "ingress-nginx", "app.kubernetes.io/component=controller")
+}
@@ -0,0 +1,119 @@
+package addons
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"myk8s-cluster/healthcheck"
+	"myk8s-cluster/kindconfig"
+	"myk8s-cluster/script"
+
+	yamlv2 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/yaml/v2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// MetalLB installs MetalLB from its native manifest and announces an
+// address pool on the Docker network of the nodes in L2 mode, so services
+// of type LoadBalancer get an IP reachable from the Docker host.
+type MetalLB struct {
+	Version string
+	// Addresses is the pool, or empty for a range of the node network.
+	Addresses []string
+}
+
+func newMetalLB(o Options) (Addon, error) {
+	a := &MetalLB{Version: orDefault(o.Version, "v0.14.9"), Addresses: o.Addresses}
+	for _, addresses := range a.Addresses {
+		if err := validAddresses(addresses); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// validAddresses checks an entry of the pool is a CIDR or a first-last range.
+func validAddresses(addresses string) error {
+	if _, _, err := net.ParseCIDR(addresses); err == nil {
+		return nil
+	}
+	first, last, ok := strings.Cut(addresses, "-")
+	if !ok || net.ParseIP(strings.TrimSpace(first)) == nil || net.ParseIP(strings.TrimSpace(last)) == nil {
+		return fmt.Errorf("addresses %q must be a CIDR or a first-last IP range", addresses)
+	}
+	return nil
+}
+
+func (a *MetalLB) Name() string { return "metallb" }
+
+func (a *MetalLB) KindConfig(*kindconfig.Cluster) {}
+
+func (a *MetalLB) manifest() string {
+	return fmt.Sprintf("https://raw.githubusercontent.com/metallb/metallb/%s/config/manifests/metallb-native.yaml", a.Version)
+}
+
+var metalLBPoolTemplate = script.New("metallb-pool", `apiVersion: metallb.io/v1beta1
+kind: IPAddressPool
+metadata:
+  name: default
+  namespace: metallb-system
+spec:
+  addresses:
+{{- range .}}
+  - {{printf "%q" .}}
+{{- end}}
+---
+apiVersion: metallb.io/v1beta1
+kind: L2Advertisement
+metadata:
+  name: default
+  namespace: metallb-system
+spec:
+  ipAddressPools:
+  - default
+`)
+
+// defaultAddresses is the .255.200-.255.250 range of the /16 kind gives the
+// Docker network of the nodes by default.
+func defaultAddresses(nodeIP string) ([]string, error) {
+	ip := net.ParseIP(nodeIP).To4()
+	if ip == nil {
+		return nil, fmt.Errorf("metallb: node IP %q is no IPv4 address, set addons.metallb.addresses", nodeIP)
+	}
+	return []string{fmt.Sprintf("%d.%d.255.200-%d.%d.255.250", ip[0], ip[1], ip[0], ip[1])}, nil
+}
+
+func (a *MetalLB) Install(ctx *pulumi.Context, name string, cluster Cluster, opts ...pulumi.ResourceOption) ([]pulumi.Resource, error) {
+	// Waits for the controller, whose webhook validates the pool
+	manifest, err := yamlv2.NewConfigGroup(ctx, name, &yamlv2.ConfigGroupArgs{
+		Files: pulumi.StringArray{pulumi.String(a.manifest())},
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	pool := cluster.NodeIP.ApplyT(func(nodeIP string) (string, error) {
+		addresses := a.Addresses
+		if len(addresses) == 0 {
+			var err error
+			if addresses, err = defaultAddresses(nodeIP); err != nil {
+				return "", err
+			}
+		}
+		return metalLBPoolTemplate.Render(addresses), nil
+	}).(pulumi.StringOutput)
+	poolGroup, err := yamlv2.NewConfigGroup(ctx, name+"-pool", &yamlv2.ConfigGroupArgs{
+		Yaml: pool,
+	}, after(opts, manifest)...)
+	if err != nil {
+		return nil, err
+	}
+	return []pulumi.Resource{manifest, poolGroup}, nil
+}
+
+func (a *MetalLB) WaitScript() string {
+	return rolloutWait("metallb-system", "app=metallb", "deploy/controller", "ds/speaker")
+}
+
+func (a *MetalLB) HealthCheck() healthcheck.Check {
+	return podsReady(a.Name(), "metallb-system", "app=metallb")
+}
@@ -0,0 +1,53 @@
+package addons
+
+import (
+	"myk8s-cluster/healthcheck"
+	"myk8s-cluster/kindconfig"
+
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/helm/v3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// MetricsServer installs metrics-server with Helm, for `kubectl top` and
+// the horizontal pod autoscaler. The kubelets of kind serve self-signed
+// certificates, so it skips verifying them.
+type MetricsServer struct {
+	Version string
+}
+
+func newMetricsServer(o Options) (Addon, error) {
+	return &MetricsServer{Version: orDefault(o.Version, "3.12.2")}, nil
+}
+
+func (a *MetricsServer) Name() string { return "metrics-server" }
+
+func (a *MetricsServer) KindConfig(*kindconfig.Cluster) {}
+
+func (a *MetricsServer) Install(ctx *pulumi.Context, name string, _ Cluster, opts ...pulumi.ResourceOption) ([]pulumi.Resource, error) {
+	release, err := helmv3.NewRelease(ctx, name, &helmv3.ReleaseArgs{
+		Name:      pulumi.String("metrics-server"),
+		Chart:     pulumi.String("metrics-server"),
+		Version:   pulumi.String(a.Version),
+		Namespace: pulumi.String("kube-system"),
+		RepositoryOpts: &helmv3.RepositoryOptsArgs{
+			Repo: pulumi.String("https://kubernetes-sigs.github.io/metrics-server/"),
+		},
+		Values: pulumi.Map{
+			"args": pulumi.StringArray{pulumi.String("--kubelet-insecure-tls")},
+		},
+		// WaitScript waits, and only warns if it is slow to come up
+		SkipAwait: pulumi.Bool(true),
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return []pulumi.Resource{release}, nil
+}
+
+func (a *MetricsServer) WaitScript() string {
+	return rolloutWait("kube-system", "app.kubernetes.io/name=metrics-server", "deploy/metrics-server")
+}
+
+func (a *MetricsServer) HealthCheck() healthcheck.Check {
+	return podsReady(a.Name(), "kube-system", "app.kubernetes.io/name=metrics-server")
+}
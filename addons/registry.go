@@ -0,0 +1,190 @@
+package addons
+
+import (
+	"fmt"
+
+	"myk8s-cluster/healthcheck"
+	"myk8s-cluster/kindconfig"
+	"myk8s-cluster/script"
+
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// registryContainerPort is the port the registry listens on in its
+// container.
+const registryContainerPort = 5000
+
+// Registry runs a container registry on the Docker host of the nodes,
+// published on localhost:<port> and attached to the kind network. Images
+// pushed to localhost:<port> on the Docker host are pulled from the same
+// name on every node, which containerd resolves to the registry container
+// through the hosts.toml written to each node, as kind documents at
+// https://kind.sigs.k8s.io/docs/user/local-registry/. It is kept, with its
+// images, when the cluster is recreated.
+type Registry struct {
+	Version     string
+	Port        int
+	ClusterName string
+	DockerHost  string
+}
+
+func newRegistry(o Options) (Addon, error) {
+	a := &Registry{
+		Version:     orDefault(o.Version, "2"),
+		Port:        orDefault(o.Port, 5001),
+		ClusterName: o.ClusterName,
+		DockerHost:  o.DockerHost,
+	}
+	return a, validPort("port", a.Port)
+}
+
+func (a *Registry) Name() string { return "registry" }
+
+// ContainerName is the name of the registry container, and its host name on
+// the kind network.
+func (a *Registry) ContainerName() string {
+	return a.ClusterName + "-registry"
+}
+
+// ContainerPort is the port of the registry on the kind network.
+func (a *Registry) ContainerPort() int {
+	return registryContainerPort
+}
+
+// Host is where images are pushed to from the Docker host, and the
+// registry part of their names in pod specs.
+func (a *Registry) Host() string {
+	return fmt.Sprintf("localhost:%d", a.Port)
+}
+
+// containerdCertsDir is where containerd on the nodes looks up the hosts of
+// a registry, in a directory named after it.
+const containerdCertsDir = "/etc/containerd/certs.d"
+
+// registryConfigPatch replaces the registry.mirrors setting containerd
+// deprecated.
+var registryConfigPatch = `[plugins."io.containerd.grpc.v1.cri".registry]
+  config_path = "` + containerdCertsDir + `"
+`
+
+var registryHostsTemplate = script.New("registry-hosts", `[host."http://{{.ContainerName}}:{{.ContainerPort}}"]`)
+
+func (a *Registry) KindConfig(config *kindconfig.Cluster) {
+	config.ContainerdConfigPatches = append(config.ContainerdConfigPatches, registryConfigPatch)
+}
+
+// HostsDir is the directory of the hosts.toml on the nodes resolving Host
+// to the registry container.
+func (a *Registry) HostsDir() string {
+	return containerdCertsDir + "/" + a.Host()
+}
+
+// Hosts is the content of that hosts.toml.
+func (a *Registry) Hosts() string {
+	return registryHostsTemplate.Render(a)
+}
+
+var registryRunScript = script.New("registry-run", `
+		export DOCKER_HOST={{.DockerHost}}
+		name={{quote .ContainerName}}
+		if [ "$(docker inspect -f '{{"{{.State.Running}}"}}' "$name" 2>/dev/null)" != true ]; then
+			echo "Starting the registry $name on localhost:{{.Port}}..."
+			docker rm -f "$name" >/dev/null 2>&1 || true
+			docker run -d --restart=always --name "$name" \
+				-p 127.0.0.1:{{.Port}}:{{.ContainerPort}} \
+				-v "$name:/var/lib/registry" \
+				{{quote (printf "registry:%s" .Version)}}
+		fi
+		# Nodes reach the registry by its container name on the kind network
+		if [ "$(docker inspect -f '{{"{{json .NetworkSettings.Networks.kind}}"}}' "$name")" = null ]; then
+			docker network connect kind "$name"
+		fi
+		# and containerd on each node finds it for {{.Host}}
+		for node in $(kind get nodes --name {{quote .ClusterName}}); do
+			docker exec "$node" mkdir -p {{quote .HostsDir}}
+			printf '%s\n' {{quote .Hosts}} | docker exec -i "$node" cp /dev/stdin {{quote (printf "%s/hosts.toml" .HostsDir)}}
+		done
+	`)
+
+var registryRemoveScript = script.New("registry-remove", `
+		export DOCKER_HOST={{.DockerHost}}
+		echo "Removing the registry "{{quote .ContainerName}}" and its images..."
+		docker rm -f {{quote .ContainerName}} 2>/dev/null || true
+		docker volume rm {{quote .ContainerName}} 2>/dev/null || true
+	`)
+
+func (a *Registry) ContainerScripts() (create, delete string) {
+	return registryRunScript.Render(a), registryRemoveScript.Render(a)
+}
+
+func (a *Registry) HostPorts() []int {
+	return []int{a.Port}
+}
+
+func (a *Registry) Install(ctx *pulumi.Context, name string, _ Cluster, opts ...pulumi.ResourceOption) ([]pulumi.Resource, error) {
+	// Documents the registry for tools building images for the cluster, see
+	// https://github.com/kubernetes/enhancements/tree/master/keps/sig-cluster-lifecycle/generic/1755-communicating-a-local-registry
+	hosting, err := corev1.NewConfigMap(ctx, name, &corev1.ConfigMapArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String("local-registry-hosting"),
+			Namespace: pulumi.String("kube-public"),
+		},
+		Data: pulumi.StringMap{
+			"localRegistryHosting.v1": pulumi.String(fmt.Sprintf("host: %q\nhostFromContainerRuntime: %q\nhelp: \"https://kind.sigs.k8s.io/docs/user/local-registry/\"\n",
+				a.Host(), fmt.Sprintf("%s:%d", a.ContainerName(), registryContainerPort))),
+		},
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return []pulumi.Resource{hosting}, nil
+}
+
+// Node is the node the registry is checked from.
+func (a *Registry) Node() string {
+	return a.ClusterName + "-control-plane"
+}
+
+var registryCheckScript = script.New("registry-check", `
+		export DOCKER_HOST={{.DockerHost}}
+		url=http://{{.ContainerName}}:{{.ContainerPort}}/v2/
+		if ! docker exec {{quote .Node}} curl -sf -o /dev/null "$url"; then
+			echo "The registry does not answer at $url on the kind network"
+			exit 1
+		fi
+		echo "The registry answers at $url on the kind network"
+	`)
+
+var registryWaitScript = script.New("registry-wait", `
+		export DOCKER_HOST={{.DockerHost}}
+		url=http://{{.ContainerName}}:{{.ContainerPort}}/v2/
+		echo "Waiting for the registry to answer at $url..."
+		attempt=0
+		until docker exec {{quote .Node}} curl -sf -o /dev/null "$url"; do
+			attempt=$((attempt+1))
+			if [ $attempt -ge 20 ]; then
+				echo "Warning: Timed out waiting for the registry"
+				exit 0
+			fi
+			sleep 3
+		done
+		echo "The registry is ready!"
+	`)
+
+func (a *Registry) WaitScript() string {
+	return registryWaitScript.Render(a)
+}
+
+func (a *Registry) HealthCheck() healthcheck.Check {
+	return healthcheck.Command(a.Name(), healthcheck.Warning, registryCheckScript.Render(a))
+}
+
+func (a *Registry) Outputs() map[string]string {
+	return map[string]string{
+		"pushUrl":       a.Host(),
+		"containerName": a.ContainerName(),
+		"networkUrl":    fmt.Sprintf("%s:%d", a.ContainerName(), registryContainerPort),
+	}
+}
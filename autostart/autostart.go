@@ -0,0 +1,110 @@
+// Package autostart runs a script when the user's session starts, so the
+// host and the kind nodes come back after a reboot: a launchd agent on macOS
+// and a systemd user unit on Linux. Like the cni and host packages, each
+// implementation is described by the shell scripts installing and removing
+// it.
+package autostart
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"myk8s-cluster/script"
+)
+
+// Autostart installs Options.Script to run at login.
+type Autostart interface {
+	// Name is the value of the `autostart` config key selecting this one.
+	Name() string
+	// InstallScript writes the script and registers it with the service
+	// manager.
+	InstallScript() string
+	// UninstallScript reverses InstallScript.
+	UninstallScript() string
+}
+
+// Special values of the `autostart` config key.
+const (
+	Auto = "auto"
+	None = "none"
+)
+
+// Options describes what to run at login.
+type Options struct {
+	// Label names the agent or unit, e.g. "myk8s-cluster.myk8s".
+	Label   string
+	HomeDir string
+	// Script is the shell script to run. It runs with the PATH of the
+	// installing shell, so commands are found where they were installed.
+	Script string
+	// Binaries must be on PATH when installing.
+	Binaries []string
+}
+
+var autostarts = map[string]func(Options) Autostart{
+	"launchd": func(o Options) Autostart { return &Launchd{Options: o} },
+	"systemd": func(o Options) Autostart { return &Systemd{Options: o} },
+}
+
+// Names lists the supported implementations, not including Auto and None.
+func Names() []string {
+	names := make([]string, 0, len(autostarts))
+	for name := range autostarts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ForOS is the implementation Auto resolves to on goos, or None.
+func ForOS(goos string) string {
+	switch goos {
+	case "darwin":
+		return "launchd"
+	case "linux":
+		return "systemd"
+	default:
+		return None
+	}
+}
+
+// New returns the implementation registered under name, or nil for None.
+func New(name string, opts Options) (Autostart, error) {
+	if name == None {
+		return nil, nil
+	}
+	newAutostart, ok := autostarts[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown autostart %q, expected %s, %s or one of %s", name, Auto, None, strings.Join(Names(), ", "))
+	}
+	return newAutostart(opts), nil
+}
+
+// ScriptPath is where the script run at login is written.
+func (o Options) ScriptPath() string {
+	return filepath.Join(o.HomeDir, ".local", "share", "myk8s-cluster", o.Label+".sh")
+}
+
+// writeScript checks the binaries and writes the login script, pinning the
+// current PATH into it as service managers start with a minimal one.
+func (o Options) writeScript() string {
+	return loginScript.Render(struct {
+		Options
+		Dir, Path string
+	}{o, filepath.Dir(o.ScriptPath()), o.ScriptPath()})
+}
+
+var loginScript = script.New("autostart-login-script", `
+			for bin in {{quoteAll .Binaries}}; do
+				if ! command -v "$bin" >/dev/null 2>&1; then
+					echo "ERROR: $bin not found in PATH"
+					exit 1
+				fi
+			done
+			mkdir -p {{quote .Dir}}
+			printf "#!/bin/sh\nexport PATH='%s'\n" "$PATH" > {{quote .Path}}
+			printf '%s\n' {{quote .Script}} >> {{quote .Path}}
+			chmod +x {{quote .Path}}
+	`)
@@ -0,0 +1,71 @@
+package autostart
+
+import (
+	"fmt"
+	"html"
+	"path/filepath"
+
+	"myk8s-cluster/script"
+)
+
+// Launchd runs the script from a launchd agent loaded at login.
+type Launchd struct {
+	Options
+}
+
+func (l *Launchd) Name() string { return "launchd" }
+
+func (l *Launchd) plistPath() string {
+	return filepath.Join(l.HomeDir, "Library", "LaunchAgents", l.Label+".plist")
+}
+
+// launchdData is what the launchd script templates are rendered with.
+type launchdData struct {
+	PlistPath  string
+	Plist      string
+	ScriptPath string
+}
+
+func (l *Launchd) data() launchdData {
+	return launchdData{
+		PlistPath: l.plistPath(),
+		Plist: fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+    <key>Label</key>
+    <string>%s</string>
+    <key>ProgramArguments</key>
+    <array>
+        <string>/bin/sh</string>
+        <string>%s</string>
+    </array>
+    <key>RunAtLoad</key>
+    <true/>
+    <key>KeepAlive</key>
+    <false/>
+</dict>
+</plist>`, html.EscapeString(l.Label), html.EscapeString(l.ScriptPath())),
+		ScriptPath: l.ScriptPath(),
+	}
+}
+
+var launchdInstallScript = script.New("launchd-install", `
+			plist={{quote .PlistPath}}
+			mkdir -p "$(dirname "$plist")"
+			printf '%s\n' {{quote .Plist}} > "$plist"
+			launchctl unload "$plist" 2>/dev/null || true
+			launchctl load "$plist"
+	`)
+
+func (l *Launchd) InstallScript() string {
+	return l.writeScript() + launchdInstallScript.Render(l.data())
+}
+
+var launchdUninstallScript = script.New("launchd-uninstall", `
+			# Unload and remove the launchd agent
+			launchctl unload {{quote .PlistPath}} 2>/dev/null || true
+			rm -f {{quote .PlistPath}} {{quote .ScriptPath}} 2>/dev/null || true
+	`)
+
+func (l *Launchd) UninstallScript() string { return launchdUninstallScript.Render(l.data()) }
@@ -0,0 +1,79 @@
+package autostart
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"myk8s-cluster/script"
+)
+
+// Systemd runs the script from a oneshot systemd --user unit started with
+// the user's manager. Without `loginctl enable-linger` that is at login
+// rather than at boot.
+type Systemd struct {
+	Options
+}
+
+func (s *Systemd) Name() string { return "systemd" }
+
+func (s *Systemd) unit() string { return s.Label + ".service" }
+
+func (s *Systemd) unitPath() string {
+	return filepath.Join(s.HomeDir, ".config", "systemd", "user", s.unit())
+}
+
+// systemdData is what the systemd script templates are rendered with.
+type systemdData struct {
+	Unit       string
+	UnitPath   string
+	UnitFile   string
+	ScriptPath string
+}
+
+func (s *Systemd) data() systemdData {
+	return systemdData{
+		Unit:     s.unit(),
+		UnitPath: s.unitPath(),
+		UnitFile: fmt.Sprintf(`[Unit]
+Description=Start %s
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+ExecStart=/bin/sh "%s"
+
+[Install]
+WantedBy=default.target`, s.Label, s.ScriptPath()),
+		ScriptPath: s.ScriptPath(),
+	}
+}
+
+// Containers and CI machines often have no user manager to enable the unit
+// with; the cluster itself works without autostart there.
+const systemdCheck = `
+			if ! systemctl --user show-environment >/dev/null 2>&1; then
+				echo "WARNING: no systemd user manager, skipping autostart"
+				exit 0
+			fi
+	`
+
+var systemdInstallScript = script.New("systemd-install", `
+			unit_path={{quote .UnitPath}}
+			mkdir -p "$(dirname "$unit_path")"
+			printf '%s\n' {{quote .UnitFile}} > "$unit_path"
+			systemctl --user daemon-reload
+			systemctl --user enable {{quote .Unit}}
+	`)
+
+func (s *Systemd) InstallScript() string {
+	return systemdCheck + s.writeScript() + systemdInstallScript.Render(s.data())
+}
+
+var systemdUninstallScript = script.New("systemd-uninstall", `
+			# Disable and remove the systemd user unit
+			systemctl --user disable {{quote .Unit}} 2>/dev/null || true
+			rm -f {{quote .UnitPath}} {{quote .ScriptPath}} 2>/dev/null || true
+			systemctl --user daemon-reload 2>/dev/null || true
+	`)
+
+func (s *Systemd) UninstallScript() string { return systemdUninstallScript.Render(s.data()) }
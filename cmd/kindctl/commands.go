@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"myk8s-cluster/healthcheck"
+	kubeconfigpkg "myk8s-cluster/kubeconfig"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optdestroy"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optup"
+)
+
+// configFlags are repeated -c key=value flags.
+type configFlags []string
+
+func (c *configFlags) String() string { return strings.Join(*c, " ") }
+
+func (c *configFlags) Set(v string) error {
+	if key, _, ok := strings.Cut(v, "="); !ok || key == "" {
+		return fmt.Errorf("%q must be key=value", v)
+	}
+	*c = append(*c, v)
+	return nil
+}
+
+// up creates the stack if needed, applies the -c settings to its config and
+// runs pulumi up.
+func up(ctx context.Context, w *stateDir, args []string) error {
+	flags := newFlagSet("up", "[-c cluster.key=value]...")
+	var config configFlags
+	flags.Var(&config, "c", "set a config `path`=value, e.g. cluster.cpus=16; repeatable")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	s, err := w.upsertStack(ctx)
+	if err != nil {
+		return err
+	}
+	for _, kv := range config {
+		key, value, _ := strings.Cut(kv, "=")
+		if err := s.SetConfigWithOptions(ctx, key, auto.ConfigValue{Value: value}, &auto.ConfigOptions{Path: true}); err != nil {
+			return err
+		}
+	}
+	// Validate before pulumi up for a short error
+	if _, err := stackSpec(ctx, s); err != nil {
+		return err
+	}
+
+	if _, err := s.Up(ctx, optup.ProgressStreams(os.Stdout), optup.ErrorProgressStreams(os.Stderr)); err != nil {
+		return fmt.Errorf("pulumi up: %s", firstLine(err))
+	}
+	fmt.Printf("\nCluster ready. To use it in this shell:\n  eval \"$(kindctl -stack %s shell-env)\"\n", w.stack)
+	return nil
+}
+
+// destroy runs pulumi destroy after asking for confirmation. The stack and
+// its config are kept for the next up.
+func destroy(ctx context.Context, w *stateDir, args []string) error {
+	flags := newFlagSet("destroy", "[-yes]")
+	yes := flags.Bool("yes", false, "don't ask for confirmation, required without a terminal")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	s, err := w.selectStack(ctx)
+	if err != nil {
+		return err
+	}
+	if !*yes {
+		ok, err := confirm(fmt.Sprintf("Destroy the cluster of stack %q? This cannot be undone.", w.stack))
+		if err != nil || !ok {
+			return err
+		}
+	}
+	if _, err := s.Destroy(ctx, optdestroy.ProgressStreams(os.Stdout), optdestroy.ErrorProgressStreams(os.Stderr)); err != nil {
+		return fmt.Errorf("pulumi destroy: %s", firstLine(err))
+	}
+	return nil
+}
+
+// confirm asks a yes/no question on the terminal.
+func confirm(question string) (bool, error) {
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false, errors.New("not a terminal, pass -yes to confirm")
+	}
+	fmt.Printf("%s Type yes to continue: ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	if strings.TrimSpace(answer) != "yes" {
+		fmt.Println("Cancelled.")
+		return false, nil
+	}
+	return true, nil
+}
+
+// status prints the stack outputs and the last update.
+func status(ctx context.Context, w *stateDir, args []string) error {
+	if err := newFlagSet("status", "").Parse(args); err != nil {
+		return err
+	}
+	s, err := w.selectStack(ctx)
+	if err != nil {
+		return err
+	}
+	outputs, err := s.Outputs(ctx)
+	if err != nil {
+		return err
+	}
+	history, err := s.History(ctx, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "stack\t%s\n", w.stack)
+	if len(outputs) == 0 {
+		fmt.Fprintln(tw, "cluster\tnot created")
+	} else {
+		for _, name := range clusterNames(outputs) {
+			cluster := clusterOutputs(outputs, name)
+			fmt.Fprintf(tw, "cluster\t%s\n", name)
+			fmt.Fprintf(tw, "kubeconfig\t%v\n", cluster["kubeconfigPath"])
+			fmt.Fprintf(tw, "health\t%s\n", healthStatus(cluster))
+		}
+	}
+	if len(history) > 0 {
+		last := history[0]
+		fmt.Fprintf(tw, "last %s\t%s at %s\n", last.Kind, last.Result, last.StartTime)
+	}
+	return tw.Flush()
+}
+
+// clusterNames lists the clusters in the outputs, the first cluster first.
+func clusterNames(outputs auto.OutputMap) []string {
+	first, _ := outputs["clusterName"].Value.(string)
+	names := []string{first}
+	clusters, _ := outputs["clusters"].Value.(map[string]any)
+	for _, name := range slices.Sorted(maps.Keys(clusters)) {
+		if name != first {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// clusterOutputs returns the outputs of the named cluster, or of the first
+// if name is empty. Stacks created before the clusters output only have the
+// first.
+func clusterOutputs(outputs auto.OutputMap, name string) map[string]any {
+	if first, _ := outputs["clusterName"].Value.(string); name == "" || name == first {
+		return map[string]any{
+			"kubeconfigPath": outputs["kubeconfigPath"].Value,
+			"healthReport":   outputs["healthReport"].Value,
+		}
+	}
+	clusters, _ := outputs["clusters"].Value.(map[string]any)
+	cluster, _ := clusters[name].(map[string]any)
+	return cluster
+}
+
+// healthStatus is the overall status of the healthReport output of a
+// cluster.
+func healthStatus(cluster map[string]any) string {
+	report, _ := cluster["healthReport"].(map[string]any)
+	if status, _ := report["status"].(string); status != "" {
+		return status
+	}
+	return "not checked"
+}
+
+// health runs the health checks of an update against the cluster, and fails
+// if a critical one fails.
+func health(ctx context.Context, w *stateDir, args []string) error {
+	flags := newFlagSet("health", "[-cluster name] [-json]")
+	clusterName := clusterFlag(flags)
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	s, err := w.selectStack(ctx)
+	if err != nil {
+		return err
+	}
+	spec, err := clusterSpec(ctx, s, *clusterName)
+	if err != nil {
+		return err
+	}
+	checks, err := spec.HealthChecks()
+	if err != nil {
+		return err
+	}
+	config, err := readKubeconfig(ctx, s, spec.ClusterName)
+	if err != nil {
+		return err
+	}
+	client, err := healthcheck.NewClient(config)
+	if err != nil {
+		return err
+	}
+	report := healthcheck.Runner{Client: client}.Run(ctx, checks...)
+
+	if *asJSON {
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	} else {
+		fmt.Print(report)
+	}
+	return report.Err()
+}
+
+// kubeconfig prints the cluster kubeconfig, or its path. The merge and
+// unmerge subcommands do what the program and the merge-kubeconfig step do
+// to ~/.kube/config.
+func kubeconfig(ctx context.Context, w *stateDir, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "merge":
+			return mergeKubeconfig(args[1:])
+		case "unmerge":
+			return unmergeKubeconfig(args[1:])
+		}
+	}
+	flags := newFlagSet("kubeconfig", "[-cluster name] [-path] | merge | unmerge")
+	clusterName := clusterFlag(flags)
+	path := flags.Bool("path", false, "print the path of the kubeconfig instead")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	s, err := w.selectStack(ctx)
+	if err != nil {
+		return err
+	}
+	if *path {
+		p, err := kubeconfigPath(ctx, s, *clusterName)
+		if err != nil {
+			return err
+		}
+		fmt.Println(p)
+		return nil
+	}
+	config, err := readKubeconfig(ctx, s, *clusterName)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(config)
+	return err
+}
+
+// mergeKubeconfig merges the current context of a kubeconfig into another.
+func mergeKubeconfig(args []string) error {
+	flags := newFlagSet("kubeconfig merge", "-from path -record path [-into path] [-context name] [-use]")
+	var opts kubeconfigpkg.MergeOptions
+	flags.StringVar(&opts.From, "from", "", "kubeconfig `path` to merge the current context of")
+	flags.StringVar(&opts.Into, "into", "", "kubeconfig `path` to merge into (default ~/.kube/config)")
+	flags.StringVar(&opts.Context, "context", "", "`name` of the merged context (default its name in -from)")
+	flags.BoolVar(&opts.Use, "use", false, "make the merged context the current context")
+	flags.StringVar(&opts.Record, "record", "", "`path` to keep the record of the merge at, for unmerge")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if opts.From == "" || opts.Record == "" {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+	rec, err := kubeconfigpkg.Merge(opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Merged context %s into %s\n", rec.Context, rec.Into)
+	return nil
+}
+
+// unmergeKubeconfig undoes a merge.
+func unmergeKubeconfig(args []string) error {
+	flags := newFlagSet("kubeconfig unmerge", "-record path")
+	record := flags.String("record", "", "`path` of the record of the merge")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *record == "" {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+	return kubeconfigpkg.Unmerge(*record)
+}
+
+// kubeconfigPath is the kubeconfigPath output of the named cluster of the
+// stack, or of the first if name is empty.
+func kubeconfigPath(ctx context.Context, s auto.Stack, name string) (string, error) {
+	outputs, err := s.Outputs(ctx)
+	if err != nil {
+		return "", err
+	}
+	path, _ := clusterOutputs(outputs, name)["kubeconfigPath"].(string)
+	switch {
+	case path != "":
+		return path, nil
+	case len(outputs) == 0:
+		return "", fmt.Errorf("stack %q has no cluster, run kindctl up first", s.Name())
+	default:
+		return "", fmt.Errorf("stack %q has no cluster %q, run kindctl up first if it was just added", s.Name(), name)
+	}
+}
+
+func readKubeconfig(ctx context.Context, s auto.Stack, name string) ([]byte, error) {
+	path, err := kubeconfigPath(ctx, s, name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// shellEnv prints the exports the cluster adds to the shell profiles.
+func shellEnv(ctx context.Context, w *stateDir, args []string) error {
+	flags := newFlagSet("shell-env", "[-cluster name]")
+	clusterName := clusterFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	s, err := w.selectStack(ctx)
+	if err != nil {
+		return err
+	}
+	spec, err := clusterSpec(ctx, s, *clusterName)
+	if err != nil {
+		return err
+	}
+	lines, err := spec.ShellEnv()
+	if err != nil {
+		return err
+	}
+	fmt.Println(strings.Join(lines, "\n"))
+	return nil
+}
+
+// clusterFlag adds the -cluster flag selecting one of the clusters of the
+// stack.
+func clusterFlag(flags *flag.FlagSet) *string {
+	return flags.String("cluster", "", "`name` of the cluster, by default the first")
+}
+
+// firstLine is the first line of an Automation API error, which goes on
+// with the output of the pulumi command already streamed to the terminal.
+func firstLine(err error) string {
+	msg, _, _ := strings.Cut(err.Error(), "\n")
+	return msg
+}
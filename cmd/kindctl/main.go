@@ -0,0 +1,103 @@
+// Command kindctl creates and manages the cluster without a checkout of this
+// repository: the Pulumi program is built in and run inline through the
+// Automation API, with the stack state in a local file backend and a
+// generated secrets passphrase under ~/.local/share/myk8s-cluster/kindctl.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+const usage = `Usage: kindctl [-stack name] <command> [flags]
+
+Commands:
+  up          create or update the cluster
+  destroy     delete the cluster and everything created with it
+  status      show the cluster, its health and the last update
+  health      run the health checks against the cluster
+  kubeconfig  print the cluster kubeconfig, or merge it into another
+  shell-env   print the cluster environment, for eval "$(kindctl shell-env)"
+
+Run kindctl <command> -h for the flags of a command.
+
+Flags:
+`
+
+// command runs a kindctl command with the arguments after its name.
+type command func(ctx context.Context, w *stateDir, args []string) error
+
+var commands = map[string]command{
+	"up":         up,
+	"destroy":    destroy,
+	"status":     status,
+	"health":     health,
+	"kubeconfig": kubeconfig,
+	"shell-env":  shellEnv,
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "kindctl:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// run parses the global flags and runs the command named in args.
+func run(ctx context.Context, args []string, stderr io.Writer) error {
+	flags := flag.NewFlagSet("kindctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	stackName := flags.String("stack", "dev", "`name` of the stack, one per cluster")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+	name := flags.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %q, must be one of %s", name, strings.Join(commandNames(), ", "))
+	}
+	w, err := newStateDir(*stackName)
+	if err != nil {
+		return err
+	}
+	return cmd(ctx, w, flags.Args()[1:])
+}
+
+func commandNames() []string {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newFlagSet returns the flag set of a command.
+func newFlagSet(name, args string) *flag.FlagSet {
+	flags := flag.NewFlagSet("kindctl "+name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: kindctl %s %s\n", name, args)
+		flags.PrintDefaults()
+	}
+	return flags
+}
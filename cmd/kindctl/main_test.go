@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+)
+
+func TestRunUsage(t *testing.T) {
+	var stderr bytes.Buffer
+	if err := run(context.Background(), nil, &stderr); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("no command: err = %v, want flag.ErrHelp", err)
+	}
+	if !strings.Contains(stderr.String(), "shell-env") {
+		t.Errorf("usage does not list the commands:\n%s", stderr.String())
+	}
+
+	err := run(context.Background(), []string{"-stack", "ci", "create"}, &stderr)
+	if err == nil || !strings.Contains(err.Error(), `unknown command "create"`) {
+		t.Errorf("unknown command: err = %v", err)
+	}
+}
+
+func TestPassphraseFile(t *testing.T) {
+	t.Setenv("PULUMI_PASSPHRASE", "")
+	w := &stateDir{dir: filepath.Join(t.TempDir(), "kindctl"), stack: "dev"}
+	path, err := w.passphraseFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 || info.Size() < 32 {
+		t.Errorf("passphrase file has mode %v and %d bytes", info.Mode().Perm(), info.Size())
+	}
+	generated, _ := os.ReadFile(path)
+
+	// The passphrase is kept, even if PULUMI_PASSPHRASE is set later
+	t.Setenv("PULUMI_PASSPHRASE", "from-env")
+	if _, err := w.passphraseFile(); err != nil {
+		t.Fatal(err)
+	}
+	if kept, _ := os.ReadFile(path); !bytes.Equal(kept, generated) {
+		t.Errorf("passphrase changed from %q to %q", generated, kept)
+	}
+
+	w.dir = filepath.Join(t.TempDir(), "kindctl")
+	path, err = w.passphraseFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "from-env" {
+		t.Errorf("passphrase = %q, want PULUMI_PASSPHRASE", got)
+	}
+}
+
+func TestConfigFlags(t *testing.T) {
+	var config configFlags
+	flags := flag.NewFlagSet("up", flag.ContinueOnError)
+	flags.SetOutput(&bytes.Buffer{})
+	flags.Var(&config, "c", "")
+	if err := flags.Parse([]string{"-c", "cluster.cpus=4", "-c", "cluster.nodes.worker2.labels.gpu=a=b"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := config.String(); got != "cluster.cpus=4 cluster.nodes.worker2.labels.gpu=a=b" {
+		t.Errorf("config = %s", got)
+	}
+	for _, bad := range []string{"cluster.cpus", "=4"} {
+		if err := flags.Parse([]string{"-c", bad}); err == nil {
+			t.Errorf("-c %s was accepted", bad)
+		}
+	}
+}
+
+func TestHealthStatus(t *testing.T) {
+	cluster := map[string]any{"healthReport": map[string]any{"status": "WARN", "checks": []any{}}}
+	if got := healthStatus(cluster); got != "WARN" {
+		t.Errorf("healthStatus = %q, want WARN", got)
+	}
+	cluster["healthReport"] = map[string]any{}
+	if got := healthStatus(cluster); got != "not checked" {
+		t.Errorf("healthStatus of an empty report = %q", got)
+	}
+}
+
+func TestClusterOutputs(t *testing.T) {
+	outputs := auto.OutputMap{
+		"clusterName":    {Value: "hub"},
+		"kubeconfigPath": {Value: "/home/dev/.kube/hub-config"},
+		"healthReport":   {Value: map[string]any{}},
+	}
+	// Stacks from before the clusters output
+	if names := clusterNames(outputs); strings.Join(names, " ") != "hub" {
+		t.Errorf("clusterNames = %v, want hub", names)
+	}
+	if got := clusterOutputs(outputs, ""); got["kubeconfigPath"] != "/home/dev/.kube/hub-config" {
+		t.Errorf("first cluster outputs = %v", got)
+	}
+
+	outputs["clusters"] = auto.OutputValue{Value: map[string]any{
+		"spoke2": map[string]any{"kubeconfigPath": "/home/dev/.kube/spoke2-config"},
+		"hub":    map[string]any{"kubeconfigPath": "/home/dev/.kube/hub-config"},
+		"spoke1": map[string]any{"kubeconfigPath": "/home/dev/.kube/spoke1-config"},
+	}}
+	if names := clusterNames(outputs); strings.Join(names, " ") != "hub spoke1 spoke2" {
+		t.Errorf("clusterNames = %v, want hub first", names)
+	}
+	if got := clusterOutputs(outputs, "spoke2"); got["kubeconfigPath"] != "/home/dev/.kube/spoke2-config" {
+		t.Errorf("spoke2 outputs = %v", got)
+	}
+	if got := clusterOutputs(outputs, "missing"); got != nil {
+		t.Errorf("missing cluster outputs = %v", got)
+	}
+}
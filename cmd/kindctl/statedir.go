@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"myk8s-cluster/kindcluster"
+	"myk8s-cluster/project"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/workspace"
+)
+
+// stateDir is where kindctl keeps the Pulumi state of its stacks:
+//
+//	state/       file backend holding the stacks
+//	project/     Pulumi.yaml and the Pulumi.<stack>.yaml stack configs
+//	passphrase   secrets passphrase of every stack
+//	pulumi/      Pulumi CLI, installed if there is none on PATH
+type stateDir struct {
+	dir   string
+	stack string
+}
+
+func newStateDir(stack string) (*stateDir, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return &stateDir{dir: filepath.Join(home, ".local", "share", "myk8s-cluster", "kindctl"), stack: stack}, nil
+}
+
+// passphraseFile returns the passphrase file, writing it first if it doesn't
+// exist: PULUMI_PASSPHRASE if set, or else 32 random bytes.
+func (w *stateDir) passphraseFile() (string, error) {
+	path := filepath.Join(w.dir, "passphrase")
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		return path, err
+	}
+	passphrase := os.Getenv("PULUMI_PASSPHRASE")
+	if passphrase == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		passphrase = base64.StdEncoding.EncodeToString(b)
+	}
+	if err := os.MkdirAll(w.dir, 0o700); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(passphrase), 0o600); err != nil {
+		return "", fmt.Errorf("writing passphrase: %w", err)
+	}
+	return path, nil
+}
+
+// pulumiCommand returns the Pulumi CLI on PATH, or installs the version
+// matching the SDK into the workspace.
+func (w *stateDir) pulumiCommand(ctx context.Context) (auto.PulumiCommand, error) {
+	if cmd, err := auto.NewPulumiCommand(nil); err == nil {
+		return cmd, nil
+	}
+	fmt.Fprintln(os.Stderr, "Installing the Pulumi CLI...")
+	cmd, err := auto.InstallPulumiCommand(ctx, &auto.PulumiCommandOptions{Root: filepath.Join(w.dir, "pulumi")})
+	if err != nil {
+		return nil, fmt.Errorf("installing the Pulumi CLI: %w", err)
+	}
+	return cmd, nil
+}
+
+// options configure the local workspace of the stacks. A passphrase set in
+// the environment takes precedence over the passphrase file.
+func (w *stateDir) options(ctx context.Context) ([]auto.LocalWorkspaceOption, error) {
+	projectDir := filepath.Join(w.dir, "project")
+	backendDir := filepath.Join(w.dir, "state")
+	for _, dir := range []string{projectDir, backendDir} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, err
+		}
+	}
+	env := map[string]string{}
+	if os.Getenv("PULUMI_CONFIG_PASSPHRASE") == "" && os.Getenv("PULUMI_CONFIG_PASSPHRASE_FILE") == "" {
+		path, err := w.passphraseFile()
+		if err != nil {
+			return nil, err
+		}
+		env["PULUMI_CONFIG_PASSPHRASE_FILE"] = path
+	}
+	cmd, err := w.pulumiCommand(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return []auto.LocalWorkspaceOption{
+		auto.WorkDir(projectDir),
+		auto.Pulumi(cmd),
+		auto.Project(workspace.Project{
+			Name:    project.Name,
+			Runtime: workspace.NewProjectRuntimeInfo("go", nil),
+			Backend: &workspace.ProjectBackend{URL: "file://" + filepath.ToSlash(backendDir)},
+		}),
+		auto.SecretsProvider("passphrase"),
+		auto.EnvVars(env),
+	}, nil
+}
+
+// upsertStack selects the stack, creating it if needed.
+func (w *stateDir) upsertStack(ctx context.Context) (auto.Stack, error) {
+	opts, err := w.options(ctx)
+	if err != nil {
+		return auto.Stack{}, err
+	}
+	return auto.UpsertStackInlineSource(ctx, w.stack, project.Name, project.Program, opts...)
+}
+
+// selectStack selects the stack, which must exist.
+func (w *stateDir) selectStack(ctx context.Context) (auto.Stack, error) {
+	opts, err := w.options(ctx)
+	if err != nil {
+		return auto.Stack{}, err
+	}
+	s, err := auto.SelectStackInlineSource(ctx, w.stack, project.Name, project.Program, opts...)
+	if auto.IsSelectStack404Error(err) {
+		return s, fmt.Errorf("no stack %q, run kindctl up first", w.stack)
+	}
+	return s, err
+}
+
+// stackSpec returns the cluster spec in the config of a stack.
+func stackSpec(ctx context.Context, s auto.Stack) (kindcluster.ClusterSpec, error) {
+	config, err := s.GetAllConfig(ctx)
+	if err != nil {
+		return kindcluster.ClusterSpec{}, err
+	}
+	return kindcluster.ParseClusterSpec(config[project.Name+":cluster"].Value)
+}
+
+// clusterSpec returns the spec of the named cluster in the config of a stack,
+// or of the first if name is empty.
+func clusterSpec(ctx context.Context, s auto.Stack, name string) (kindcluster.ClusterSpec, error) {
+	spec, err := stackSpec(ctx, s)
+	if err != nil {
+		return spec, err
+	}
+	specs, err := spec.ClusterSpecs()
+	if err != nil {
+		return spec, err
+	}
+	for _, spec := range specs {
+		if name == "" || spec.ClusterName == name {
+			return spec, nil
+		}
+	}
+	return spec, fmt.Errorf("stack %q has no cluster %q", s.Name(), name)
+}
@@ -0,0 +1,172 @@
+package cni
+
+import (
+	"fmt"
+
+	"myk8s-cluster/healthcheck"
+	"myk8s-cluster/script"
+
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	yamlv2 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/yaml/v2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Calico installs Calico from its single-file manifest and switches the
+// default IP pool to VXLAN, which works better under nested virtualization.
+type Calico struct {
+	Version  string
+	Manifest string
+}
+
+func (c *Calico) Name() string            { return "calico" }
+func (c *Calico) DisableDefaultCNI() bool { return true }
+
+func (c *Calico) Install(ctx *pulumi.Context, name string, opts ...pulumi.ResourceOption) ([]pulumi.Resource, error) {
+	manifest, err := yamlv2.NewConfigGroup(ctx, name, &yamlv2.ConfigGroupArgs{
+		Files: pulumi.StringArray{pulumi.String(c.Manifest)},
+		// WaitScript waits, and only warns if Calico is slow to come up
+		SkipAwait: pulumi.Bool(true),
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Configure Calico for VXLAN mode (better for nested virtualization)
+	vxlan, err := appsv1.NewDaemonSetPatch(ctx, name+"-vxlan", &appsv1.DaemonSetPatchArgs{
+		Metadata: &metav1.ObjectMetaPatchArgs{
+			Name:      pulumi.String("calico-node"),
+			Namespace: pulumi.String("kube-system"),
+			// The manifest sets these variables too
+			Annotations: pulumi.StringMap{"pulumi.com/patchForce": pulumi.String("true")},
+		},
+		Spec: &appsv1.DaemonSetSpecPatchArgs{
+			Template: &corev1.PodTemplateSpecPatchArgs{
+				Spec: &corev1.PodSpecPatchArgs{
+					Containers: corev1.ContainerPatchArray{&corev1.ContainerPatchArgs{
+						Name: pulumi.String("calico-node"),
+						Env: corev1.EnvVarPatchArray{
+							&corev1.EnvVarPatchArgs{Name: pulumi.String("CALICO_IPV4POOL_VXLAN"), Value: pulumi.String("Always")},
+							&corev1.EnvVarPatchArgs{Name: pulumi.String("CALICO_IPV4POOL_IPIP"), Value: pulumi.String("Off")},
+						},
+					}},
+				},
+			},
+		},
+	}, after(opts, manifest)...)
+	if err != nil {
+		return nil, err
+	}
+	return []pulumi.Resource{manifest, vxlan}, nil
+}
+
+func (c *Calico) WaitScript() string {
+	return `
+		echo "Waiting for Calico pods to be ready..."
+
+		timeout=120
+		interval=3
+		elapsed=0
+		while [ $elapsed -lt $timeout ]; do
+			# Use kubectl wait for efficiency
+			if kubectl wait --for=condition=ready pods -l k8s-app=calico-node -n kube-system --timeout=3s 2>/dev/null; then
+				echo "All Calico pods are ready!"
+				break
+			fi
+
+			# Fallback to manual checking if kubectl wait fails
+			ready_pods=$(kubectl -n kube-system get pods -l k8s-app=calico-node -o jsonpath='{.items[*].status.containerStatuses[*].ready}' | tr ' ' '\n' | grep -c "true" || echo "0")
+			desired_pods=$(kubectl -n kube-system get pods -l k8s-app=calico-node --no-headers | wc -l | tr -d ' ')
+
+			if [ "$ready_pods" -eq "$desired_pods" ] && [ "$desired_pods" -ge 1 ]; then
+				echo "All Calico pods are ready ($ready_pods/$desired_pods)."
+				break
+			fi
+
+			echo "Waiting for Calico pods... ($ready_pods/$desired_pods ready)"
+			sleep $interval
+			elapsed=$((elapsed + interval))
+		done
+
+		if [ $elapsed -ge $timeout ]; then
+			echo "Warning: Timed out waiting for Calico pods to be ready"
+			kubectl -n kube-system get pods -l k8s-app=calico-node
+		fi
+	`
+}
+
+func (c *Calico) HealthCheck() healthcheck.Check {
+	return podsReady("kube-system", "k8s-app=calico-node")
+}
+
+// CalicoOperator installs Calico through the Tigera operator with a VXLAN
+// Installation resource whose IP pool matches the cluster pod subnet.
+type CalicoOperator struct {
+	Version   string
+	PodSubnet string
+}
+
+func (c *CalicoOperator) Name() string            { return "calico-operator" }
+func (c *CalicoOperator) DisableDefaultCNI() bool { return true }
+
+func (c *CalicoOperator) operatorManifest() string {
+	return fmt.Sprintf("https://raw.githubusercontent.com/projectcalico/calico/%s/manifests/tigera-operator.yaml", c.Version)
+}
+
+var calicoInstallationTemplate = script.New("calico-installation", `apiVersion: operator.tigera.io/v1
+kind: Installation
+metadata:
+  name: default
+spec:
+  calicoNetwork:
+    ipPools:
+    - cidr: {{printf "%q" .PodSubnet}}
+      encapsulation: VXLAN
+      natOutgoing: Enabled
+      nodeSelector: all()
+---
+apiVersion: operator.tigera.io/v1
+kind: APIServer
+metadata:
+  name: default
+spec: {}
+`)
+
+func (c *CalicoOperator) Install(ctx *pulumi.Context, name string, opts ...pulumi.ResourceOption) ([]pulumi.Resource, error) {
+	// Waits for the operator to roll out
+	operator, err := yamlv2.NewConfigGroup(ctx, name+"-operator", &yamlv2.ConfigGroupArgs{
+		Files: pulumi.StringArray{pulumi.String(c.operatorManifest())},
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	installation, err := yamlv2.NewConfigGroup(ctx, name, &yamlv2.ConfigGroupArgs{
+		Yaml: pulumi.String(calicoInstallationTemplate.Render(c)),
+	}, after(opts, operator)...)
+	if err != nil {
+		return nil, err
+	}
+	return []pulumi.Resource{operator, installation}, nil
+}
+
+func (c *CalicoOperator) WaitScript() string {
+	return `
+		echo "Waiting for Calico to be reported available by the operator..."
+		attempt=0
+		until kubectl get tigerastatus/calico >/dev/null 2>&1 || [ $attempt -ge 40 ]; do
+			sleep 3
+			attempt=$((attempt+1))
+		done
+		if kubectl wait --for=condition=Available tigerastatus/calico --timeout=180s; then
+			echo "Calico is ready!"
+		else
+			echo "Warning: Timed out waiting for Calico to be ready"
+			kubectl get tigerastatus
+		fi
+	`
+}
+
+func (c *CalicoOperator) HealthCheck() healthcheck.Check {
+	return podsReady("calico-system", "k8s-app=calico-node")
+}
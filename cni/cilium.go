@@ -0,0 +1,55 @@
+package cni
+
+import (
+	"myk8s-cluster/healthcheck"
+
+	helmv3 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/helm/v3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Cilium installs Cilium with Helm from the upstream chart repository.
+type Cilium struct {
+	Version string
+}
+
+func (c *Cilium) Name() string            { return "cilium" }
+func (c *Cilium) DisableDefaultCNI() bool { return true }
+
+func (c *Cilium) Install(ctx *pulumi.Context, name string, opts ...pulumi.ResourceOption) ([]pulumi.Resource, error) {
+	release, err := helmv3.NewRelease(ctx, name, &helmv3.ReleaseArgs{
+		Name:      pulumi.String("cilium"),
+		Chart:     pulumi.String("cilium"),
+		Version:   pulumi.String(c.Version),
+		Namespace: pulumi.String("kube-system"),
+		RepositoryOpts: &helmv3.RepositoryOptsArgs{
+			Repo: pulumi.String("https://helm.cilium.io"),
+		},
+		Values: pulumi.Map{
+			"image": pulumi.Map{"pullPolicy": pulumi.String("IfNotPresent")},
+			"ipam":  pulumi.Map{"mode": pulumi.String("kubernetes")},
+		},
+		// WaitScript waits, and only warns if Cilium is slow to come up
+		SkipAwait: pulumi.Bool(true),
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return []pulumi.Resource{release}, nil
+}
+
+// MigrateScript uninstalls the release the helm CLI installed, which a
+// Release resource cannot adopt.
+func (c *Cilium) MigrateScript() string {
+	return `
+		echo "Removing the Cilium release installed by the helm CLI..."
+		helm uninstall cilium --namespace kube-system --wait 2>/dev/null || true
+	`
+}
+
+func (c *Cilium) WaitScript() string {
+	return rolloutWait("kube-system", "ds/cilium", "k8s-app=cilium")
+}
+
+func (c *Cilium) HealthCheck() healthcheck.Check {
+	return podsReady("kube-system", "k8s-app=cilium")
+}
@@ -0,0 +1,127 @@
+// Package cni provides the pod network plugins that can be installed into the
+// kind cluster. Each plugin registers its own Kubernetes resources through
+// the cluster's provider, and supplies the shell script waiting for it to be
+// ready, which expects KUBECONFIG to be exported by the caller, and the
+// health check of its pods.
+package cni
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"myk8s-cluster/healthcheck"
+	"myk8s-cluster/script"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// CNI is a pod network plugin.
+type CNI interface {
+	// Name is the value of the `cni` config key selecting this plugin.
+	Name() string
+	// DisableDefaultCNI reports whether kind must skip installing kindnet.
+	DisableDefaultCNI() bool
+	// Install registers the Kubernetes resources of the plugin, named after
+	// name, with opts selecting the provider and parent. It returns them, or
+	// nil if there is nothing to install.
+	Install(ctx *pulumi.Context, name string, opts ...pulumi.ResourceOption) ([]pulumi.Resource, error)
+	// WaitScript blocks until the plugin is ready, or is empty if there is
+	// nothing to wait for.
+	WaitScript() string
+	// HealthCheck checks the plugin is running, or is nil if there is
+	// nothing to check.
+	HealthCheck() healthcheck.Check
+}
+
+// Binaries is implemented by plugins whose scripts run commands other than
+// kubectl.
+type Binaries interface {
+	Binaries() []string
+}
+
+// Migrator is implemented by plugins whose resources cannot take over what
+// the install scripts of earlier versions installed.
+type Migrator interface {
+	// MigrateScript removes what the install script installed, so the
+	// resources can be created in its place. KUBECONFIG is exported when it
+	// runs.
+	MigrateScript() string
+}
+
+// Options carries the plugin-specific settings from stack config.
+type Options struct {
+	CalicoVersion string
+	// CalicoManifest is the URL or local path of the manifest.
+	CalicoManifest string
+	CiliumVersion  string
+	FlannelVersion string
+	// PodSubnet is the cluster pod CIDR, used by plugins that need it spelled
+	// out in their own config.
+	PodSubnet string
+}
+
+var plugins = map[string]func(Options) CNI{
+	"calico":          func(o Options) CNI { return &Calico{Version: o.CalicoVersion, Manifest: o.CalicoManifest} },
+	"calico-operator": func(o Options) CNI { return &CalicoOperator{Version: o.CalicoVersion, PodSubnet: o.PodSubnet} },
+	"cilium":          func(o Options) CNI { return &Cilium{Version: o.CiliumVersion} },
+	"flannel":         func(o Options) CNI { return &Flannel{Version: o.FlannelVersion} },
+	"kindnet":         func(Options) CNI { return Kindnet{} },
+	"none":            func(Options) CNI { return None{} },
+}
+
+// Names lists the supported plugins.
+func Names() []string {
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns the plugin registered under name.
+func New(name string, opts Options) (CNI, error) {
+	newPlugin, ok := plugins[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown cni %q, expected one of %s", name, strings.Join(Names(), ", "))
+	}
+	return newPlugin(opts), nil
+}
+
+// after appends a dependency on resources to opts, leaving opts alone.
+func after(opts []pulumi.ResourceOption, resources ...pulumi.Resource) []pulumi.ResourceOption {
+	return append(slices.Clip(opts), pulumi.DependsOn(resources))
+}
+
+// rolloutWait waits for a workload to roll out but, like the rest of the
+// setup, only warns on timeout so the health checks can report the details.
+func rolloutWait(namespace, workload, selector string) string {
+	return rolloutWaitScript.Render(workloadData{Namespace: namespace, Workload: workload, Selector: selector})
+}
+
+// workloadData is what the shared script templates are rendered with.
+type workloadData struct {
+	Name      string
+	Namespace string
+	Workload  string
+	Selector  string
+}
+
+var rolloutWaitScript = script.New("rollout-wait", `
+		workload={{quote .Workload}}
+		echo "Waiting for $workload in "{{quote .Namespace}}" to be ready..."
+		if kubectl -n {{quote .Namespace}} rollout status "$workload" --timeout=120s; then
+			echo "$workload is ready!"
+		else
+			echo "Warning: Timed out waiting for $workload to be ready"
+			kubectl -n {{quote .Namespace}} get pods -l {{quote .Selector}}
+		fi
+	`)
+
+// podsReady is the health check of a plugin running the pods matching
+// selector.
+func podsReady(namespace, selector string) healthcheck.Check {
+	return healthcheck.PodsReady("cni", namespace, selector, healthcheck.Critical)
+}
@@ -0,0 +1,43 @@
+package cni
+
+import (
+	"fmt"
+
+	"myk8s-cluster/healthcheck"
+
+	yamlv2 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/yaml/v2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Flannel installs Flannel from its release manifest. It expects the kind
+// default pod subnet 10.244.0.0/16, which the cluster spec enforces.
+type Flannel struct {
+	Version string
+}
+
+func (f *Flannel) Name() string            { return "flannel" }
+func (f *Flannel) DisableDefaultCNI() bool { return true }
+
+func (f *Flannel) manifest() string {
+	return fmt.Sprintf("https://github.com/flannel-io/flannel/releases/download/%s/kube-flannel.yml", f.Version)
+}
+
+func (f *Flannel) Install(ctx *pulumi.Context, name string, opts ...pulumi.ResourceOption) ([]pulumi.Resource, error) {
+	manifest, err := yamlv2.NewConfigGroup(ctx, name, &yamlv2.ConfigGroupArgs{
+		Files: pulumi.StringArray{pulumi.String(f.manifest())},
+		// WaitScript waits, and only warns if Flannel is slow to come up
+		SkipAwait: pulumi.Bool(true),
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return []pulumi.Resource{manifest}, nil
+}
+
+func (f *Flannel) WaitScript() string {
+	return rolloutWait("kube-flannel", "ds/kube-flannel-ds", "app=flannel")
+}
+
+func (f *Flannel) HealthCheck() healthcheck.Check {
+	return podsReady("kube-flannel", "app=flannel")
+}
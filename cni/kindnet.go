@@ -0,0 +1,25 @@
+package cni
+
+import (
+	"myk8s-cluster/healthcheck"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Kindnet keeps the CNI kind installs by default.
+type Kindnet struct{}
+
+func (Kindnet) Name() string            { return "kindnet" }
+func (Kindnet) DisableDefaultCNI() bool { return false }
+
+func (Kindnet) Install(*pulumi.Context, string, ...pulumi.ResourceOption) ([]pulumi.Resource, error) {
+	return nil, nil
+}
+
+func (Kindnet) WaitScript() string {
+	return rolloutWait("kube-system", "ds/kindnet", "app=kindnet")
+}
+
+func (Kindnet) HealthCheck() healthcheck.Check {
+	return podsReady("kube-system", "app=kindnet")
+}
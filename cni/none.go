@@ -0,0 +1,29 @@
+package cni
+
+import (
+	"context"
+	"errors"
+
+	"myk8s-cluster/healthcheck"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"k8s.io/client-go/kubernetes"
+)
+
+// None disables kindnet and installs nothing, for bringing your own CNI.
+// Nodes stay NotReady until a CNI is installed.
+type None struct{}
+
+func (None) Name() string            { return "none" }
+func (None) DisableDefaultCNI() bool { return true }
+func (None) WaitScript() string      { return "" }
+
+func (None) Install(*pulumi.Context, string, ...pulumi.ResourceOption) ([]pulumi.Resource, error) {
+	return nil, nil
+}
+
+func (None) HealthCheck() healthcheck.Check {
+	return healthcheck.Func("cni", healthcheck.Warning, 0, func(context.Context, kubernetes.Interface) (string, healthcheck.Counts, error) {
+		return "", nil, healthcheck.Permanent(errors.New("no CNI installed (cni: none)"))
+	})
+}
@@ -0,0 +1,137 @@
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// APIServer checks that the API server answers.
+func APIServer() Check {
+	return Func("api-server", Critical, 30*time.Second, func(ctx context.Context, client kubernetes.Interface) (string, Counts, error) {
+		version, err := client.Discovery().ServerVersion()
+		if err != nil {
+			return "", nil, fmt.Errorf("API server not reachable: %w", err)
+		}
+		return "Kubernetes " + version.GitVersion, nil, nil
+	})
+}
+
+// NodesReady checks that expected nodes are registered and Ready. It counts
+// the expected, registered and ready nodes.
+func NodesReady(expected int, severity Severity) Check {
+	return Func("nodes", severity, 0, func(ctx context.Context, client kubernetes.Interface) (string, Counts, error) {
+		nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return "", nil, err
+		}
+		ready := 0
+		for _, node := range nodes.Items {
+			if nodeReady(node) {
+				ready++
+			}
+		}
+		counts := Counts{"expected": expected, "registered": len(nodes.Items), "ready": ready}
+		msg := fmt.Sprintf("%d/%d nodes Ready", ready, expected)
+		if len(nodes.Items) != expected || ready != expected {
+			return "", counts, fmt.Errorf("%s, %d registered", msg, len(nodes.Items))
+		}
+		return msg, counts, nil
+	})
+}
+
+func nodeReady(node corev1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// PodsReady checks that there are pods matching selector in namespace and
+// that all of them are Ready or have completed. An empty selector matches
+// every pod in the namespace. It counts the total and ready pods.
+func PodsReady(name, namespace, selector string, severity Severity) Check {
+	return Func(name, severity, 0, func(ctx context.Context, client kubernetes.Interface) (string, Counts, error) {
+		pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return "", nil, err
+		}
+		ready := 0
+		var notReady []string
+		for _, pod := range pods.Items {
+			if podReady(pod) {
+				ready++
+			} else {
+				notReady = append(notReady, pod.Name)
+			}
+		}
+		counts := Counts{"total": len(pods.Items), "ready": ready}
+		msg := fmt.Sprintf("%d/%d pods Ready in %s", ready, len(pods.Items), namespace)
+		switch {
+		case len(pods.Items) == 0:
+			return "", counts, fmt.Errorf("no pods matching %q in %s", selector, namespace)
+		case len(notReady) > 0:
+			return "", counts, fmt.Errorf("%s, not ready: %s", msg, strings.Join(notReady, ", "))
+		}
+		return msg, counts, nil
+	})
+}
+
+func podReady(pod corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodSucceeded {
+		return true
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// SystemPods checks the pods in kube-system.
+func SystemPods() Check {
+	return PodsReady("system-pods", "kube-system", "", Warning)
+}
+
+// CoreDNS checks the cluster DNS pods.
+func CoreDNS() Check {
+	return PodsReady("coredns", "kube-system", "k8s-app=kube-dns", Warning)
+}
+
+// Command checks by running a shell script on this machine, for what can't
+// be seen through the API, like the VM the cluster runs in. The check passes
+// if the script exits 0; its last line of output is the message.
+func Command(name string, severity Severity, script string) Check {
+	return Func(name, severity, 30*time.Second, func(ctx context.Context, _ kubernetes.Interface) (string, Counts, error) {
+		cmd := exec.CommandContext(ctx, "/bin/sh", "-c", script)
+		var out bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &out, &out
+		err := cmd.Run()
+		msg := lastLine(out.String())
+		if err != nil {
+			if msg == "" {
+				return "", nil, err
+			}
+			return "", nil, fmt.Errorf("%s (%w)", msg, err)
+		}
+		return msg, nil, nil
+	})
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	return s[strings.LastIndex(s, "\n")+1:]
+}
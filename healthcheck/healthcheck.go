@@ -0,0 +1,254 @@
+// Package healthcheck checks that a kind cluster is up, mostly through the
+// Kubernetes API. Checks are retried until they pass or their timeout runs
+// out, and each has a severity deciding whether its failure fails the update
+// or is only reported.
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Severity is how much a failing check matters.
+type Severity int
+
+const (
+	// Warning failures are reported but leave the cluster usable.
+	Warning Severity = iota
+	// Critical failures mean the cluster is broken.
+	Critical
+)
+
+func (s Severity) String() string {
+	if s == Critical {
+		return "critical"
+	}
+	return "warning"
+}
+
+// MarshalText marshals the severity as its String.
+func (s Severity) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	Pass Status = "PASS"
+	// Warn is a failed Warning check.
+	Warn Status = "WARN"
+	// Fail is a failed Critical check.
+	Fail Status = "FAIL"
+)
+
+// Check is a single health check.
+type Check interface {
+	// Name identifies the check in the report, e.g. "nodes".
+	Name() string
+	Severity() Severity
+	// Timeout bounds all attempts of the check; zero uses the Runner's.
+	Timeout() time.Duration
+	// Run checks once and describes what it found, e.g. "4/4 nodes
+	// Ready", with the numbers behind it in counts. It returns an error
+	// if the check failed, along with whatever counts it got.
+	Run(ctx context.Context, client kubernetes.Interface) (msg string, counts Counts, err error)
+}
+
+// Counts are the numbers a check is based on, e.g. the ready and total
+// pods.
+type Counts map[string]int
+
+// Func returns a Check calling run.
+func Func(name string, severity Severity, timeout time.Duration, run func(context.Context, kubernetes.Interface) (string, Counts, error)) Check {
+	return &funcCheck{name: name, severity: severity, timeout: timeout, run: run}
+}
+
+type funcCheck struct {
+	name     string
+	severity Severity
+	timeout  time.Duration
+	run      func(context.Context, kubernetes.Interface) (string, Counts, error)
+}
+
+func (c *funcCheck) Name() string           { return c.name }
+func (c *funcCheck) Severity() Severity     { return c.severity }
+func (c *funcCheck) Timeout() time.Duration { return c.timeout }
+
+func (c *funcCheck) Run(ctx context.Context, client kubernetes.Interface) (string, Counts, error) {
+	return c.run(ctx, client)
+}
+
+// Permanent wraps the error of a check that is not worth retrying.
+func Permanent(err error) error {
+	return &permanentError{err}
+}
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// NewClient returns a clientset for the cluster in a kubeconfig.
+func NewClient(kubeconfig []byte) (kubernetes.Interface, error) {
+	config, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig: %w", err)
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// Runner runs checks against a cluster.
+type Runner struct {
+	Client kubernetes.Interface
+	// Timeout bounds checks without a timeout of their own, 2m if zero.
+	Timeout time.Duration
+	// Interval is the wait between attempts of a failing check, 5s if
+	// zero.
+	Interval time.Duration
+}
+
+// Run runs the checks concurrently and reports them in the order given.
+func (r Runner) Run(ctx context.Context, checks ...Check) Report {
+	report := Report{Time: time.Now(), Results: make([]Result, len(checks))}
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Results[i] = r.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+// run retries check until it passes, times out or fails permanently.
+func (r Runner) run(ctx context.Context, check Check) Result {
+	timeout := check.Timeout()
+	if timeout == 0 {
+		timeout = r.Timeout
+	}
+	if timeout == 0 {
+		timeout = 2 * time.Minute
+	}
+	interval := r.Interval
+	if interval == 0 {
+		interval = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	result := Result{Name: check.Name(), Severity: check.Severity()}
+attempts:
+	for {
+		msg, counts, err := check.Run(ctx, r.Client)
+		if err == nil {
+			result.Status, result.Message, result.Counts = Pass, msg, counts
+			break
+		}
+		// An attempt cut short by the timeout says less than the one before
+		if ctx.Err() == nil || result.Message == "" {
+			result.Message, result.Counts = err.Error(), counts
+		}
+		result.Status = Warn
+		if check.Severity() == Critical {
+			result.Status = Fail
+		}
+		if errors.As(err, new(*permanentError)) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			break attempts
+		case <-time.After(interval):
+		}
+	}
+	result.Duration = time.Since(start)
+	return result
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name     string   `json:"name"`
+	Severity Severity `json:"severity"`
+	Status   Status   `json:"status"`
+	// Message is what the check found, or why it failed.
+	Message string `json:"message"`
+	Counts  Counts `json:"counts,omitempty"`
+	// Duration is marshalled as seconds.
+	Duration time.Duration `json:"-"`
+}
+
+// MarshalJSON adds the duration in seconds.
+func (r Result) MarshalJSON() ([]byte, error) {
+	type result Result
+	return json.Marshal(struct {
+		result
+		Duration float64 `json:"duration"`
+	}{result(r), r.Duration.Seconds()})
+}
+
+// Report is the outcome of a Runner.Run. It is marshalled as
+// {"status", "time", "checks"}.
+type Report struct {
+	// Time is when the checks started.
+	Time    time.Time
+	Results []Result
+}
+
+func (r Report) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Status Status    `json:"status"`
+		Time   time.Time `json:"time"`
+		Checks []Result  `json:"checks"`
+	}{r.Status(), r.Time, r.Results})
+}
+
+// Status is Fail if any check failed, else Warn if any warned, else Pass.
+func (r Report) Status() Status {
+	status := Pass
+	for _, result := range r.Results {
+		switch result.Status {
+		case Fail:
+			return Fail
+		case Warn:
+			status = Warn
+		}
+	}
+	return status
+}
+
+// Err reports every failed Critical check, or is nil.
+func (r Report) Err() error {
+	var errs []error
+	for _, result := range r.Results {
+		if result.Status == Fail {
+			errs = append(errs, fmt.Errorf("%s: %s", result.Name, result.Message))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("critical health checks failed:\n%w", errors.Join(errs...))
+}
+
+// String lists the results one per line.
+func (r Report) String() string {
+	width := 0
+	for _, result := range r.Results {
+		width = max(width, len(result.Name))
+	}
+	var b strings.Builder
+	for _, result := range r.Results {
+		fmt.Fprintf(&b, "%-4s  %-*s  %s\n", result.Status, width, result.Name, result.Message)
+	}
+	return b.String()
+}
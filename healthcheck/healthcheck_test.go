@@ -0,0 +1,279 @@
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"maps"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func node(name string, ready bool) *corev1.Node {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+			{Type: corev1.NodeReady, Status: status},
+		}},
+	}
+}
+
+func pod(namespace, name, app string, phase corev1.PodPhase, ready bool) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: map[string]string{"k8s-app": app}},
+		Status: corev1.PodStatus{Phase: phase, Conditions: []corev1.PodCondition{
+			{Type: corev1.PodReady, Status: status},
+		}},
+	}
+}
+
+// healthyCluster has two Ready nodes and Ready CoreDNS and calico pods.
+func healthyCluster() []runtime.Object {
+	return []runtime.Object{
+		node("dev-control-plane", true),
+		node("dev-worker", true),
+		pod("kube-system", "coredns-1", "kube-dns", corev1.PodRunning, true),
+		pod("kube-system", "calico-node-1", "calico-node", corev1.PodRunning, true),
+		pod("kube-system", "install-job", "job", corev1.PodSucceeded, false),
+	}
+}
+
+// newClient is a fake clientset of a v1.31.0 cluster holding objects.
+func newClient(objects ...runtime.Object) *fake.Clientset {
+	client := fake.NewClientset(objects...)
+	client.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{GitVersion: "v1.31.0"}
+	return client
+}
+
+// shortTimeout overrides the timeout of a check so failures are quick.
+type shortTimeout struct{ Check }
+
+func (shortTimeout) Timeout() time.Duration { return 50 * time.Millisecond }
+
+// run runs a single check with a short timeout.
+func run(client kubernetes.Interface, check Check) Result {
+	return Runner{Client: client, Interval: time.Millisecond}.Run(context.Background(), shortTimeout{check}).Results[0]
+}
+
+func TestChecks(t *testing.T) {
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		check   Check
+		status  Status
+		message string
+	}{
+		{"api server", nil, APIServer(), Pass, "Kubernetes v1.31.0"},
+		{"nodes ready", healthyCluster(), NodesReady(2, Critical), Pass, "2/2 nodes Ready"},
+		{"node not ready", []runtime.Object{node("a", true), node("b", false)}, NodesReady(2, Critical), Fail, "1/2 nodes Ready, 2 registered"},
+		{"node missing", []runtime.Object{node("a", true)}, NodesReady(2, Critical), Fail, "1/2 nodes Ready, 1 registered"},
+		{"system pods", healthyCluster(), SystemPods(), Pass, "3/3 pods Ready in kube-system"},
+		{"coredns", healthyCluster(), CoreDNS(), Pass, "1/1 pods Ready in kube-system"},
+		{"coredns missing", nil, CoreDNS(), Warn, `no pods matching "k8s-app=kube-dns" in kube-system`},
+		{
+			"pod not ready",
+			[]runtime.Object{pod("kube-system", "calico-node-1", "calico-node", corev1.PodRunning, false)},
+			PodsReady("cni", "kube-system", "k8s-app=calico-node", Critical),
+			Fail, "0/1 pods Ready in kube-system, not ready: calico-node-1",
+		},
+		{
+			"pod pending",
+			[]runtime.Object{pod("kube-system", "coredns-1", "kube-dns", corev1.PodPending, true)},
+			CoreDNS(), Warn, "not ready: coredns-1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := run(newClient(tt.objects...), tt.check)
+			if result.Status != tt.status || !strings.Contains(result.Message, tt.message) {
+				t.Errorf("got %s %q, want %s %q", result.Status, result.Message, tt.status, tt.message)
+			}
+		})
+	}
+}
+
+func TestAPIServerDown(t *testing.T) {
+	client := newClient()
+	client.PrependReactor("get", "version", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+	result := run(client, APIServer())
+	if result.Status != Fail || !strings.Contains(result.Message, "connection refused") {
+		t.Errorf("got %s %q", result.Status, result.Message)
+	}
+}
+
+func TestRunnerRetries(t *testing.T) {
+	var attempts atomic.Int32
+	check := Func("flaky", Critical, time.Second, func(context.Context, kubernetes.Interface) (string, Counts, error) {
+		if attempts.Add(1) < 3 {
+			return "", nil, errors.New("not yet")
+		}
+		return "ok", nil, nil
+	})
+	result := run(newClient(), check)
+	if result.Status != Pass || result.Message != "ok" || attempts.Load() != 3 {
+		t.Errorf("got %s %q after %d attempts", result.Status, result.Message, attempts.Load())
+	}
+}
+
+func TestRunnerPermanent(t *testing.T) {
+	var attempts atomic.Int32
+	check := Func("gone", Warning, time.Second, func(context.Context, kubernetes.Interface) (string, Counts, error) {
+		attempts.Add(1)
+		return "", nil, Permanent(errors.New("nothing to check"))
+	})
+	result := run(newClient(), check)
+	if result.Status != Warn || result.Message != "nothing to check" || attempts.Load() != 1 {
+		t.Errorf("got %s %q after %d attempts", result.Status, result.Message, attempts.Load())
+	}
+}
+
+func TestRunnerTimeoutKeepsLastFailure(t *testing.T) {
+	check := Func("slow", Critical, 20*time.Millisecond, func(ctx context.Context, _ kubernetes.Interface) (string, Counts, error) {
+		if ctx.Err() != nil {
+			return "", nil, ctx.Err()
+		}
+		return "", nil, errors.New("3/4 nodes Ready")
+	})
+	result := Runner{Client: newClient(), Interval: 50 * time.Millisecond}.Run(context.Background(), check).Results[0]
+	if result.Status != Fail || result.Message != "3/4 nodes Ready" {
+		t.Errorf("got %s %q", result.Status, result.Message)
+	}
+	if result.Duration > time.Second {
+		t.Errorf("check ran for %s with a 20ms timeout", result.Duration)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	if result := run(nil, Command("host", Critical, `echo starting; echo "VM is Running"`)); result.Status != Pass || result.Message != "VM is Running" {
+		t.Errorf("passing command: got %s %q", result.Status, result.Message)
+	}
+	if result := run(nil, Command("host", Critical, `echo "VM is Stopped"; exit 1`)); result.Status != Fail || !strings.HasPrefix(result.Message, "VM is Stopped") {
+		t.Errorf("failing command: got %s %q", result.Status, result.Message)
+	}
+}
+
+func TestReport(t *testing.T) {
+	client := newClient(node("a", true), node("b", false))
+	report := Runner{Client: client, Interval: time.Millisecond}.Run(context.Background(),
+		shortTimeout{APIServer()},
+		shortTimeout{NodesReady(2, Critical)},
+		shortTimeout{CoreDNS()},
+	)
+
+	var names []string
+	for _, r := range report.Results {
+		names = append(names, r.Name)
+	}
+	if got := strings.Join(names, " "); got != "api-server nodes coredns" {
+		t.Errorf("results out of order: %s", got)
+	}
+	if report.Status() != Fail {
+		t.Errorf("status = %s, want FAIL", report.Status())
+	}
+	err := report.Err()
+	if err == nil || !strings.Contains(err.Error(), "nodes: 1/2 nodes Ready") || strings.Contains(err.Error(), "coredns") {
+		t.Errorf("Err() = %v, want only the failed critical check", err)
+	}
+	want := "PASS  api-server  Kubernetes v1.31.0\n" +
+		"FAIL  nodes       1/2 nodes Ready, 2 registered\n" +
+		"WARN  coredns     no pods matching \"k8s-app=kube-dns\" in kube-system\n"
+	if got := report.String(); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+
+	// Warnings alone don't fail the update
+	report = Report{Results: []Result{{Name: "coredns", Status: Warn}, {Name: "nodes", Status: Pass}}}
+	if report.Status() != Warn || report.Err() != nil {
+		t.Errorf("warnings only: status %s, err %v", report.Status(), report.Err())
+	}
+}
+
+func TestReportJSON(t *testing.T) {
+	client := newClient(node("a", true), node("b", false))
+	report := Runner{Client: client, Interval: time.Millisecond}.Run(context.Background(),
+		shortTimeout{APIServer()},
+		shortTimeout{NodesReady(2, Critical)},
+	)
+	out, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Status string    `json:"status"`
+		Time   time.Time `json:"time"`
+		Checks []struct {
+			Name     string         `json:"name"`
+			Severity string         `json:"severity"`
+			Status   string         `json:"status"`
+			Message  string         `json:"message"`
+			Counts   map[string]int `json:"counts"`
+			Duration *float64       `json:"duration"`
+		} `json:"checks"`
+	}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "FAIL" || got.Time.IsZero() || len(got.Checks) != 2 {
+		t.Fatalf("unexpected report: %s", out)
+	}
+	nodes := got.Checks[1]
+	if nodes.Name != "nodes" || nodes.Severity != "critical" || nodes.Status != "FAIL" || nodes.Duration == nil {
+		t.Errorf("unexpected nodes result: %s", out)
+	}
+	if want := map[string]int{"expected": 2, "registered": 2, "ready": 1}; !maps.Equal(nodes.Counts, want) {
+		t.Errorf("nodes counts = %v, want %v", nodes.Counts, want)
+	}
+	if got.Checks[0].Counts != nil {
+		t.Errorf("api-server has counts: %v", got.Checks[0].Counts)
+	}
+}
+
+func TestReportJUnit(t *testing.T) {
+	report := Report{Results: []Result{
+		{Name: "nodes", Severity: Critical, Status: Fail, Message: "1/2 nodes Ready", Duration: 2 * time.Second},
+		{Name: "coredns", Severity: Warning, Status: Warn, Message: "no pods"},
+		{Name: "api-server", Severity: Critical, Status: Pass, Message: "Kubernetes v1.31.0"},
+	}}
+	out, err := report.JUnit("dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var suite junitSuite
+	if err := xml.Unmarshal(out, &suite); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, out)
+	}
+	if suite.Name != "dev" || suite.Tests != 3 || suite.Failures != 1 || len(suite.Cases) != 3 {
+		t.Fatalf("unexpected suite:\n%s", out)
+	}
+	if c := suite.Cases[0]; c.Failure == nil || c.Failure.Message != "1/2 nodes Ready" || c.Time != 2 {
+		t.Errorf("failed check not a failure:\n%s", out)
+	}
+	if c := suite.Cases[1]; c.Failure != nil || c.SystemOut != "WARN: no pods" {
+		t.Errorf("warning not reported as output:\n%s", out)
+	}
+	if c := suite.Cases[2]; c.Failure != nil || c.SystemOut != "" {
+		t.Errorf("passed check has output:\n%s", out)
+	}
+}
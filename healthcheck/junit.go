@@ -0,0 +1,54 @@
+package healthcheck
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// junitSuite is the JUnit XML understood by CI systems: one test case per
+// check, failed checks as failures and warnings as output.
+type junitSuite struct {
+	XMLName   xml.Name    `xml:"testsuite"`
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Time      float64     `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+}
+
+// JUnit returns the report as a JUnit XML test suite named name. Failed
+// checks are failures; warnings pass with the message as their output.
+func (r Report) JUnit(name string) ([]byte, error) {
+	suite := junitSuite{Name: name, Tests: len(r.Results), Timestamp: r.Time.UTC().Format("2006-01-02T15:04:05")}
+	for _, result := range r.Results {
+		c := junitCase{Name: result.Name, Classname: name, Time: result.Duration.Seconds()}
+		switch result.Status {
+		case Fail:
+			c.Failure = &junitFailure{Message: result.Message, Type: result.Severity.String()}
+			suite.Failures++
+		case Warn:
+			c.SystemOut = fmt.Sprintf("%s: %s", result.Status, result.Message)
+		}
+		suite.Time = max(suite.Time, c.Time)
+		suite.Cases = append(suite.Cases, c)
+	}
+	out, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
@@ -0,0 +1,46 @@
+package host
+
+import "myk8s-cluster/script"
+
+// Colima runs Docker in a Colima profile.
+type Colima struct {
+	VMOptions
+}
+
+func (c *Colima) Name() string   { return "colima" }
+func (c *Colima) Binary() string { return "colima" }
+
+func (c *Colima) Create() string {
+	return script.Render("colima-create",
+		`colima start --profile {{quote .Name}} --runtime docker --cpu {{.CPUs}} --memory {{.Memory}} --disk {{.Disk}} --vm-type {{.VMType}}`, struct {
+			VMOptions
+			VMType string
+		}{c.VMOptions, macOSVMType()})
+}
+
+func (c *Colima) Start() string {
+	return script.Render("colima-start", `colima start --profile {{quote .Name}}`, c.VMOptions)
+}
+
+func (c *Colima) Stop() string {
+	return script.Render("colima-stop", `colima stop --profile {{quote .Name}} 2>/dev/null || true`, c.VMOptions)
+}
+
+func (c *Colima) Delete() string {
+	return script.Render("colima-delete", `colima delete --force --profile {{quote .Name}} 2>/dev/null || true`, c.VMOptions)
+}
+
+// Resize starts the profile with the new size, which colima applies to an
+// existing VM.
+func (c *Colima) Resize() string {
+	return script.Render("colima-resize",
+		`colima start --profile {{quote .Name}} --cpu {{.CPUs}} --memory {{.Memory}} --disk {{.Disk}}`, c.VMOptions)
+}
+
+func (c *Colima) Status() string {
+	return script.Render("colima-status", `colima list 2>/dev/null | awk -v name={{quote .Name}} 'NR > 1 && $1 == name { print $2; found=1 } END { if (!found) print "Missing" }'`, c.VMOptions)
+}
+
+func (c *Colima) DockerHost() string {
+	return script.Render("colima-docker-host", `unix://"$HOME"/.colima/{{quote .Name}}/docker.sock`, c.VMOptions)
+}
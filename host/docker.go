@@ -0,0 +1,59 @@
+package host
+
+// Docker uses the Docker daemon already running on this machine, so there is
+// no VM to create. Rootless selects the per-user daemon started by
+// dockerd-rootless-setuptool.sh instead of the system one.
+type Docker struct {
+	Rootless bool
+}
+
+func (d *Docker) Name() string {
+	if d.Rootless {
+		return "rootless-docker"
+	}
+	return "docker"
+}
+
+func (d *Docker) DockerHost() string {
+	if d.Rootless {
+		return `unix://"${XDG_RUNTIME_DIR:-/run/user/$(id -u)}"/docker.sock`
+	}
+	return "unix:///var/run/docker.sock"
+}
+
+// DockerContext is empty: the local daemon is already the default context.
+func (d *Docker) DockerContext() string { return "" }
+
+func (d *Docker) ProvisionScript() string {
+	return `
+		echo "Using local Docker daemon at $DOCKER_HOST"
+		if ! docker info >/dev/null 2>&1; then
+			echo "ERROR: Docker is not reachable at $DOCKER_HOST"
+			echo "Start it (or rootless Docker) and make sure your user can access the socket"
+			exit 1
+		fi
+		echo "Docker $(docker version --format '{{.Server.Version}}') is ready"
+	`
+}
+
+// TeardownScript is empty: the daemon is not ours to remove.
+func (d *Docker) TeardownScript() string { return "" }
+
+// AutostartScript is empty: dockerd is started by the init system.
+func (d *Docker) AutostartScript() string { return "" }
+
+func (d *Docker) Binaries() []string { return []string{"docker"} }
+
+func (d *Docker) HealthCheckScript() string {
+	return `
+		if ! docker info >/dev/null 2>&1; then
+			echo "Docker daemon at $DOCKER_HOST is not reachable"
+			exit 1
+		fi
+		echo "Docker daemon at $DOCKER_HOST is running"
+	`
+}
+
+// PreflightScript fails if the daemon is not reachable: it is not ours to
+// start.
+func (d *Docker) PreflightScript() string { return d.HealthCheckScript() }
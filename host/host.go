@@ -0,0 +1,112 @@
+// Package host provides the machines the kind node containers can run on:
+// a VM (Lima, Colima, Podman machine or Multipass), or the local Docker
+// daemon on Linux. Like the cni package, each host is described by the shell
+// scripts that bring it up, tear it down and check its health.
+package host
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Host is where the Docker daemon running the kind nodes lives.
+type Host interface {
+	// Name is the value of the `host` config key selecting this host.
+	Name() string
+	// DockerHost is the DOCKER_HOST for the daemon as a single shell word.
+	// It may reference shell variables such as $HOME, so scripts insert it
+	// as it is rather than quoting it.
+	DockerHost() string
+	// DockerContext is the docker context to create for the daemon, or
+	// empty to leave the user's contexts alone.
+	DockerContext() string
+	// ProvisionScript brings the host up and waits until Docker is usable.
+	ProvisionScript() string
+	// TeardownScript removes what ProvisionScript created, or is empty if
+	// the host is not ours to remove.
+	TeardownScript() string
+	// AutostartScript starts the host after a reboot, or is empty if the
+	// host does not need to be started by us.
+	AutostartScript() string
+	// Binaries lists the commands the scripts above run.
+	Binaries() []string
+	// HealthCheckScript prints the host state and exits non-zero unless
+	// the host is running. DOCKER_HOST is exported when it runs.
+	HealthCheckScript() string
+}
+
+// ConfigFile is implemented by hosts created from a config file rendered by
+// this program. The file is written to path before ProvisionScript runs; an
+// empty path means there is none.
+type ConfigFile interface {
+	ConfigFile() (path string, content []byte, err error)
+}
+
+// Resizer is implemented by hosts whose size can be changed in place.
+type Resizer interface {
+	// ResizeScript applies the configured size to the running host and
+	// leaves it running again.
+	ResizeScript() string
+}
+
+// Preflighter is implemented by hosts that can tell, before anything is
+// created, whether they can be provisioned as they are.
+type Preflighter interface {
+	// PreflightScript prints why and exits non-zero if ProvisionScript
+	// would fail. DOCKER_HOST is exported when it runs.
+	PreflightScript() string
+}
+
+// Auto picks the default host for the current OS.
+const Auto = "auto"
+
+// Options carries the host settings from stack config.
+type Options struct {
+	// VMBackend selects the backend of the vm host, see VMBackendNames.
+	VMBackend string
+	VM        VMOptions
+}
+
+var hosts = map[string]func(Options) (Host, error){
+	"vm": func(o Options) (Host, error) {
+		backend, err := NewVMBackend(o.VMBackend, o.VM)
+		if err != nil {
+			return nil, err
+		}
+		return &VM{Backend: backend, VMName: o.VM.Name}, nil
+	},
+	"docker":          func(Options) (Host, error) { return &Docker{}, nil },
+	"rootless-docker": func(Options) (Host, error) { return &Docker{Rootless: true}, nil },
+}
+
+// Names lists the supported hosts, not including Auto.
+func Names() []string {
+	names := make([]string, 0, len(hosts))
+	for name := range hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns the host registered under name. Auto resolves to a VM on
+// macOS and the local Docker daemon everywhere else.
+func New(name string, opts Options) (Host, error) {
+	if name == Auto {
+		name = "docker"
+		if runtime.GOOS == "darwin" {
+			name = "vm"
+		}
+	}
+	// "lima" predates the VM backends and is kept as a shorthand.
+	if name == "lima" {
+		name, opts.VMBackend = "vm", "lima"
+	}
+	newHost, ok := hosts[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown host %q, expected %s or one of %s", name, Auto, strings.Join(Names(), ", "))
+	}
+	return newHost(opts)
+}
@@ -0,0 +1,75 @@
+package host
+
+import (
+	"fmt"
+
+	"myk8s-cluster/script"
+)
+
+// Lima runs Docker in a Lima VM created from the instance config in
+// VMOptions.Lima.
+type Lima struct {
+	VMOptions
+}
+
+func (l *Lima) Name() string   { return "lima" }
+func (l *Lima) Binary() string { return "limactl" }
+
+func (l *Lima) configPath() string {
+	return fmt.Sprintf("./lima-%s.yaml", l.VMOptions.Name)
+}
+
+// ConfigFile renders the instance config, sized from VMOptions and using the
+// native hypervisor unless vmType is set.
+func (l *Lima) ConfigFile() (string, []byte, error) {
+	inst := l.Lima
+	inst.CPUs = l.CPUs
+	inst.Memory = fmt.Sprintf("%dGiB", l.Memory)
+	inst.Disk = fmt.Sprintf("%dGiB", l.Disk)
+	if inst.VMType == "" {
+		inst.VMType = macOSVMType()
+	}
+	content, err := inst.Render()
+	if err != nil {
+		return "", nil, fmt.Errorf("lima: %w", err)
+	}
+	return l.configPath(), content, nil
+}
+
+func (l *Lima) Create() string {
+	return script.Render("lima-create", `limactl create --tty=false --name {{quote .Name}} {{quote .ConfigPath}}
+				limactl start --tty=false {{quote .Name}}`, struct {
+		VMOptions
+		ConfigPath string
+	}{l.VMOptions, l.configPath()})
+}
+
+func (l *Lima) Start() string {
+	return script.Render("lima-start", `limactl start --tty=false {{quote .Name}}`, l.VMOptions)
+}
+
+func (l *Lima) Stop() string {
+	return script.Render("lima-stop", `limactl stop {{quote .Name}} 2>/dev/null || true`, l.VMOptions)
+}
+
+func (l *Lima) Delete() string {
+	return script.Render("lima-delete", `limactl delete --force {{quote .Name}} 2>/dev/null || true
+		# Clean up any leftover sockets and temp files
+		rm -rf "$HOME"/.lima/{{quote .Name}}/sock/* 2>/dev/null || true`, l.VMOptions)
+}
+
+func (l *Lima) Resize() string {
+	return script.Render("lima-resize",
+		`limactl edit --tty=false --cpus {{.CPUs}} --memory {{.Memory}} --disk {{.Disk}} {{quote .Name}}`, l.VMOptions)
+}
+
+func (l *Lima) Status() string {
+	return script.Render("lima-status", `limactl list --format {{quote .Format}} 2>/dev/null | awk -v name={{quote .Name}} '$1 == name { print $2; found=1 } END { if (!found) print "Missing" }'`, struct {
+		VMOptions
+		Format string
+	}{l.VMOptions, "{{.Name}} {{.Status}}"})
+}
+
+func (l *Lima) DockerHost() string {
+	return script.Render("lima-docker-host", `unix://"$HOME"/.lima/{{quote .Name}}/sock/docker.sock`, l.VMOptions)
+}
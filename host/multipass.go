@@ -0,0 +1,50 @@
+package host
+
+import "myk8s-cluster/script"
+
+// Multipass runs Docker in an Ubuntu Multipass instance and reaches it over
+// SSH with the user's key. Multipass does not forward ports, so kind's API
+// server is only published inside the instance; reach it through an SSH
+// tunnel or set networking.apiServerAddress to the instance address.
+type Multipass struct {
+	VMOptions
+}
+
+func (m *Multipass) Name() string   { return "multipass" }
+func (m *Multipass) Binary() string { return "multipass" }
+
+func (m *Multipass) Create() string {
+	return script.Render("multipass-create", `pubkey=$(cat "$HOME"/.ssh/id_ed25519.pub "$HOME"/.ssh/id_rsa.pub 2>/dev/null | head -n 1)
+				if [ -z "$pubkey" ]; then
+					echo "ERROR: multipass needs an SSH key in ~/.ssh/id_ed25519.pub or ~/.ssh/id_rsa.pub"
+					exit 1
+				fi
+				printf '#cloud-config\npackages: [docker.io]\nssh_authorized_keys: ["%s"]\nruncmd:\n  - usermod -aG docker ubuntu\n' "$pubkey" |
+					multipass launch 24.04 --name {{quote .Name}} --cpus {{.CPUs}} --memory {{.Memory}}G --disk {{.Disk}}G --cloud-init -`, m.VMOptions)
+}
+
+func (m *Multipass) Start() string {
+	return script.Render("multipass-start", `multipass start {{quote .Name}}`, m.VMOptions)
+}
+
+func (m *Multipass) Stop() string {
+	return script.Render("multipass-stop", `multipass stop {{quote .Name}} 2>/dev/null || true`, m.VMOptions)
+}
+
+func (m *Multipass) Delete() string {
+	return script.Render("multipass-delete", `multipass delete --purge {{quote .Name}} 2>/dev/null || true`, m.VMOptions)
+}
+
+func (m *Multipass) Resize() string {
+	return script.Render("multipass-resize", `multipass set local.{{quote .Name}}.cpus={{.CPUs}}
+				multipass set local.{{quote .Name}}.memory={{.Memory}}G
+				multipass set local.{{quote .Name}}.disk={{.Disk}}G`, m.VMOptions)
+}
+
+func (m *Multipass) Status() string {
+	return script.Render("multipass-status", `multipass list --format csv 2>/dev/null | awk -F, -v name={{quote .Name}} '$1 == name { print $2; found=1 } END { if (!found) print "Missing" }'`, m.VMOptions)
+}
+
+func (m *Multipass) DockerHost() string {
+	return script.Render("multipass-docker-host", `ssh://ubuntu@"$(multipass info {{quote .Name}} --format csv | awk -F, 'NR == 2 { print $3 }')"`, m.VMOptions)
+}
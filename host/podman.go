@@ -0,0 +1,60 @@
+package host
+
+import "myk8s-cluster/script"
+
+// Podman runs a rootful Podman machine and talks to its Docker-compatible API
+// socket, so kind keeps using its docker provider.
+type Podman struct {
+	VMOptions
+}
+
+func (p *Podman) Name() string   { return "podman" }
+func (p *Podman) Binary() string { return "podman" }
+
+// podmanOptions adds the values podman takes in other units or formats.
+type podmanOptions struct {
+	VMOptions
+	MemoryMB int
+	Format   string
+}
+
+func (p *Podman) options(format string) podmanOptions {
+	return podmanOptions{VMOptions: p.VMOptions, MemoryMB: p.Memory * 1024, Format: format}
+}
+
+func (p *Podman) Create() string {
+	return script.Render("podman-create", `podman machine init {{quote .Name}} --cpus {{.CPUs}} --memory {{.MemoryMB}} --disk-size {{.Disk}} --rootful
+				podman machine start {{quote .Name}}`, p.options(""))
+}
+
+func (p *Podman) Start() string {
+	return script.Render("podman-start", `podman machine start {{quote .Name}}`, p.VMOptions)
+}
+
+func (p *Podman) Stop() string {
+	return script.Render("podman-stop", `podman machine stop {{quote .Name}} 2>/dev/null || true`, p.VMOptions)
+}
+
+func (p *Podman) Delete() string {
+	return script.Render("podman-delete", `podman machine rm --force {{quote .Name}} 2>/dev/null || true`, p.VMOptions)
+}
+
+func (p *Podman) Resize() string {
+	return script.Render("podman-resize",
+		`podman machine set --cpus {{.CPUs}} --memory {{.MemoryMB}} --disk-size {{.Disk}} {{quote .Name}}`, p.options(""))
+}
+
+func (p *Podman) Status() string {
+	return script.Render("podman-status", `case "$(podman machine inspect {{quote .Name}} --format {{quote .Format}} 2>/dev/null)" in
+				running) echo Running ;;
+				"") echo Missing ;;
+				*) echo Stopped ;;
+			esac`, p.options("{{.State}}"))
+}
+
+// DockerHost asks podman for the API socket, whose location differs between
+// platforms and podman versions.
+func (p *Podman) DockerHost() string {
+	return script.Render("podman-docker-host", `unix://"$(podman machine inspect {{quote .Name}} --format {{quote .Format}})"`,
+		p.options("{{.ConnectionInfo.PodmanSocket.Path}}"))
+}
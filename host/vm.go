@@ -0,0 +1,265 @@
+package host
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+
+	"myk8s-cluster/limaconfig"
+	"myk8s-cluster/script"
+)
+
+// VMBackend manages the lifecycle of a VM running a Docker-compatible daemon.
+// Every method returns a shell snippet; VM strings them together into the
+// provisioning, teardown and health check scripts.
+type VMBackend interface {
+	// Name is the value of the `vmBackend` config key selecting this backend.
+	Name() string
+	// Create creates and boots a VM that does not exist yet.
+	Create() string
+	// Start boots an existing, stopped VM.
+	Start() string
+	// Stop shuts the VM down.
+	Stop() string
+	// Delete removes the VM and anything it leaves behind.
+	Delete() string
+	// Resize applies CPUs, Memory and Disk to the stopped VM. Disks can only
+	// grow.
+	Resize() string
+	// Status prints Running, Stopped or Missing.
+	Status() string
+	// DockerHost is the DOCKER_HOST for the daemon inside the VM.
+	DockerHost() string
+	// Binary is the command managing the VMs.
+	Binary() string
+}
+
+// VMOptions sizes the VM.
+type VMOptions struct {
+	Name   string
+	CPUs   int
+	Memory int // GB
+	Disk   int // GB
+	// Lima is the instance config of the lima backend. Its size is taken
+	// from the fields above.
+	Lima limaconfig.Instance
+}
+
+var vmBackends = map[string]func(VMOptions) VMBackend{
+	"lima":      func(o VMOptions) VMBackend { return &Lima{VMOptions: o} },
+	"colima":    func(o VMOptions) VMBackend { return &Colima{VMOptions: o} },
+	"podman":    func(o VMOptions) VMBackend { return &Podman{VMOptions: o} },
+	"multipass": func(o VMOptions) VMBackend { return &Multipass{VMOptions: o} },
+}
+
+// VMBackendNames lists the supported VM backends.
+func VMBackendNames() []string {
+	names := make([]string, 0, len(vmBackends))
+	for name := range vmBackends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewVMBackend returns the backend registered under name.
+func NewVMBackend(name string, opts VMOptions) (VMBackend, error) {
+	newBackend, ok := vmBackends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown vmBackend %q, expected one of %s", name, strings.Join(VMBackendNames(), ", "))
+	}
+	return newBackend(opts), nil
+}
+
+// macOSVMType is the native hypervisor on macOS; elsewhere backends fall
+// back to QEMU.
+func macOSVMType() string {
+	if runtime.GOOS == "darwin" {
+		return "vz"
+	}
+	return "qemu"
+}
+
+// VM is a Host running Docker inside a VM managed by a VMBackend.
+type VM struct {
+	Backend VMBackend
+	VMName  string
+}
+
+func (v *VM) Name() string          { return "vm" }
+func (v *VM) DockerHost() string    { return v.Backend.DockerHost() }
+func (v *VM) DockerContext() string { return v.Backend.Name() + "-" + v.VMName }
+
+// vmData is what the VM script templates are rendered with.
+type vmData struct {
+	VMName  string
+	Backend string
+	Create  string
+	Start   string
+	Stop    string
+	Delete  string
+	Resize  string
+	Status  string
+}
+
+func (v *VM) data() vmData {
+	return vmData{
+		VMName:  v.VMName,
+		Backend: v.Backend.Name(),
+		Create:  v.Backend.Create(),
+		Start:   v.Backend.Start(),
+		Stop:    v.Backend.Stop(),
+		Delete:  v.Backend.Delete(),
+		Resize:  v.Backend.Resize(),
+		Status:  v.Backend.Status(),
+	}
+}
+
+// vmPrelude defines vm_status and the variables used by the scripts below.
+const vmPrelude = `
+		vm_name={{quote .VMName}}
+		vm_backend={{quote .Backend}}
+		vm_status() {
+			{{.Status}}
+		}
+`
+
+var provisionScript = script.New("vm-provision", vmPrelude+`
+		# Create or start the VM depending on its current state
+		case "$(vm_status)" in
+			Running)
+				echo "VM $vm_name is already running"
+				;;
+			Missing)
+				echo "Creating new $vm_backend VM $vm_name..."
+				{{.Create}}
+				;;
+			*)
+				echo "VM $vm_name exists but not running, starting..."
+				{{.Start}}
+				;;
+		esac
+
+		# Wait for VM to be fully ready with retry logic
+		max_attempts=30
+		attempt=0
+		while [ $attempt -lt $max_attempts ]; do
+			if [ "$(vm_status)" = "Running" ]; then
+				echo "VM $vm_name is ready"
+				break
+			fi
+			echo "Waiting for VM to be ready... (attempt $((attempt+1))/$max_attempts)"
+			sleep 2
+			attempt=$((attempt+1))
+		done
+
+		if [ $attempt -eq $max_attempts ]; then
+			echo "ERROR: VM failed to start after $max_attempts attempts"
+			exit 1
+		fi
+	`)
+
+func (v *VM) ProvisionScript() string { return provisionScript.Render(v.data()) }
+
+var teardownScript = script.New("vm-teardown", vmPrelude+`
+		# Stop the VM first (required before deletion)
+		echo "Stopping $vm_backend VM $vm_name..."
+		{{.Stop}}
+
+		# Wait for VM to stop
+		max_attempts=30
+		attempt=0
+		while [ $attempt -lt $max_attempts ]; do
+			if [ "$(vm_status)" != "Running" ]; then
+				echo "VM $vm_name stopped successfully"
+				break
+			fi
+			echo "Waiting for VM to stop... (attempt $((attempt+1))/$max_attempts)"
+			sleep 2
+			attempt=$((attempt+1))
+		done
+
+		echo "Deleting $vm_backend VM $vm_name..."
+		{{.Delete}}
+
+		echo "$vm_backend VM $vm_name cleanup completed"
+	`)
+
+func (v *VM) TeardownScript() string { return teardownScript.Render(v.data()) }
+
+var resizeScript = script.New("vm-resize", vmPrelude+`
+		echo "Stopping $vm_backend VM $vm_name to resize it..."
+		{{.Stop}}
+		max_attempts=30
+		attempt=0
+		while [ "$(vm_status)" = "Running" ] && [ $attempt -lt $max_attempts ]; do
+			sleep 2
+			attempt=$((attempt+1))
+		done
+
+		echo "Resizing $vm_backend VM $vm_name..."
+		{{.Resize}}
+
+		echo "Starting $vm_backend VM $vm_name..."
+		if [ "$(vm_status)" != "Running" ]; then
+			{{.Start}}
+		fi
+		attempt=0
+		while [ "$(vm_status)" != "Running" ]; do
+			if [ $attempt -ge $max_attempts ]; then
+				echo "ERROR: VM failed to start after resizing"
+				exit 1
+			fi
+			sleep 2
+			attempt=$((attempt+1))
+		done
+	`)
+
+// ResizeScript stops the VM, applies the configured size and starts it
+// again, waiting until it is back up.
+func (v *VM) ResizeScript() string { return resizeScript.Render(v.data()) }
+
+var autostartScript = script.New("vm-autostart", vmPrelude+`
+		if [ "$(vm_status)" != "Running" ]; then
+			{{.Start}}
+		fi
+	`)
+
+func (v *VM) AutostartScript() string { return autostartScript.Render(v.data()) }
+
+func (v *VM) Binaries() []string { return []string{v.Backend.Binary(), "docker"} }
+
+// ConfigFile forwards to the backend, if it is created from a config file.
+func (v *VM) ConfigFile() (string, []byte, error) {
+	if c, ok := v.Backend.(ConfigFile); ok {
+		return c.ConfigFile()
+	}
+	return "", nil, nil
+}
+
+var healthCheckScript = script.New("vm-health-check", vmPrelude+`
+		status=$(vm_status)
+		echo "$vm_backend VM $vm_name is $status"
+		[ "$status" = "Running" ]
+	`)
+
+func (v *VM) HealthCheckScript() string { return healthCheckScript.Render(v.data()) }
+
+var preflightScript = script.New("vm-preflight", vmPrelude+`
+		status=$(vm_status)
+		case "$status" in
+			Running|Stopped|Missing)
+				echo "$vm_backend VM $vm_name is $status"
+				;;
+			*)
+				echo "$vm_backend VM $vm_name is $status, repair or delete it first"
+				exit 1
+				;;
+		esac
+	`)
+
+// PreflightScript fails if the VM exists in a state other than running or
+// stopped, e.g. a broken Lima instance, which the provision script would
+// neither create nor start.
+func (v *VM) PreflightScript() string { return preflightScript.Render(v.data()) }
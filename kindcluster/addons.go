@@ -0,0 +1,154 @@
+package kindcluster
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+
+	"myk8s-cluster/addons"
+	"myk8s-cluster/kindconfig"
+	"myk8s-cluster/preflight"
+
+	"github.com/pulumi/pulumi-command/sdk/go/command/local"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// addons returns the add-ons enabled in the `addons` config block, in name
+// order.
+func (s ClusterSpec) addons() ([]addons.Addon, error) {
+	// An invalid host is reported by Validate
+	dockerHost := ""
+	if h, err := s.hostBackend(); err == nil {
+		dockerHost = h.DockerHost()
+	}
+	var enabled []addons.Addon
+	for _, name := range slices.Sorted(maps.Keys(s.Addons)) {
+		settings := s.Addons[name]
+		if !settings.IsEnabled() {
+			continue
+		}
+		addon, err := addons.New(name, addons.Options{Settings: settings, ClusterName: s.ClusterName, DockerHost: dockerHost})
+		if err != nil {
+			return nil, err
+		}
+		enabled = append(enabled, addon)
+	}
+	return enabled, nil
+}
+
+func (s ClusterSpec) validateAddons() []error {
+	if _, err := s.addons(); err != nil {
+		return []error{err}
+	}
+	return nil
+}
+
+// addonContainerPorts are the host ports published by the containers of the
+// add-ons.
+func (s ClusterSpec) addonContainerPorts() []preflight.Port {
+	enabled, _ := s.addons()
+	var ports []preflight.Port
+	for _, addon := range enabled {
+		if c, ok := addon.(addons.Container); ok {
+			for _, port := range c.HostPorts() {
+				ports = append(ports, preflight.Port{Address: "127.0.0.1", Port: port, Protocol: "tcp"})
+			}
+		}
+	}
+	return ports
+}
+
+// kindConfigMissing returns the enabled add-ons whose changes to the kind
+// config are missing from the one at path, which create-kind-cluster keeps
+// when it creates the cluster. Kind only reads its config then, so these
+// add-ons can't work until the cluster is recreated. Without a kept config,
+// as for clusters created by earlier versions, it returns none.
+func (s ClusterSpec) kindConfigMissing(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	created, err := kindconfig.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	enabled, _ := s.addons()
+	var missing []string
+	for _, addon := range enabled {
+		// Only what the add-on adds, on as many nodes
+		changes := kindconfig.Cluster{Nodes: make([]kindconfig.Node, len(created.Nodes))}
+		if len(changes.Nodes) == 0 {
+			continue
+		}
+		addon.KindConfig(&changes)
+		if !created.Contains(changes) {
+			missing = append(missing, addon.Name())
+		}
+	}
+	return missing, nil
+}
+
+// addonOutputs describes the add-ons implementing addons.Outputs, keyed by
+// name.
+func addonOutputs(enabled []addons.Addon) pulumi.Map {
+	outputs := pulumi.Map{}
+	for _, addon := range enabled {
+		if o, ok := addon.(addons.Outputs); ok {
+			outputs[addon.Name()] = pulumi.ToStringMap(o.Outputs())
+		}
+	}
+	return outputs
+}
+
+// installAddons registers the resources of every add-on, their containers
+// and the commands waiting for them and tearing down what they leave behind.
+// It returns what resolves once the add-ons are ready.
+func (c *KindCluster) installAddons(ctx *pulumi.Context, enabled []addons.Addon, cluster addons.Cluster, data scriptData, kubeconfigReady pulumi.Resource, opts []pulumi.ResourceOption) ([]pulumi.Output, error) {
+	env := pulumi.StringMap{"KUBECONFIG": pulumi.String(data.KubeconfigPath)}
+	var ready []pulumi.Output
+	for _, addon := range enabled {
+		addonOpts := opts
+		// Registered first, so it is deleted after the add-on
+		if t, ok := addon.(addons.Teardown); ok {
+			teardown, err := local.NewCommand(ctx, c.childName("teardown-"+addon.Name()), &local.CommandArgs{
+				Create:      pulumi.String(`echo "Nothing to do until the add-on is removed"`),
+				Delete:      pulumi.String(withKubeconfigScript.Render(data.with(t.TeardownScript()))),
+				Environment: env,
+			}, c.opts("teardown-"+addon.Name(), pulumi.DependsOn([]pulumi.Resource{kubeconfigReady}))...)
+			if err != nil {
+				return nil, err
+			}
+			addonOpts = append(slices.Clip(opts), pulumi.DependsOn([]pulumi.Resource{teardown}))
+		}
+		resources, err := addon.Install(ctx, c.childName("addon-"+addon.Name()), cluster, addonOpts...)
+		if err != nil {
+			return nil, err
+		}
+		if ac, ok := addon.(addons.Container); ok {
+			create, remove := ac.ContainerScripts()
+			container, err := local.NewCommand(ctx, c.childName(addon.Name()+"-container"), &local.CommandArgs{
+				Create: pulumi.String(create),
+				Delete: pulumi.String(remove),
+			}, c.opts(addon.Name()+"-container", pulumi.DependsOn([]pulumi.Resource{kubeconfigReady}))...)
+			if err != nil {
+				return nil, err
+			}
+			resources = append(resources, container)
+		}
+		waitFor, err := local.NewCommand(ctx, c.childName("wait-for-"+addon.Name()), &local.CommandArgs{
+			Create:      pulumi.String(withKubeconfigScript.Render(data.with(addon.WaitScript()))),
+			Environment: env,
+		}, c.opts("wait-for-"+addon.Name(), pulumi.DependsOn([]pulumi.Resource{kubeconfigReady}), pulumi.DependsOn(resources))...)
+		if err != nil {
+			return nil, err
+		}
+		ready = append(ready, waitFor.Stdout)
+	}
+	return ready, nil
+}
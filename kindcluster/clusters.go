@@ -0,0 +1,169 @@
+package kindcluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// hostSpec is the part of the spec every cluster on a host shares.
+type hostSpec struct {
+	Host      string
+	VMBackend string
+	Autostart string
+	Lima      LimaSpec
+	VMName    string
+	CPUs      int
+	Memory    int
+	Disk      int
+}
+
+func (s ClusterSpec) hostSpec() hostSpec {
+	return hostSpec{
+		Host:      s.Host,
+		VMBackend: s.VMBackend,
+		Autostart: s.Autostart,
+		Lima:      s.Lima,
+		VMName:    s.VMName,
+		CPUs:      s.CPUs,
+		Memory:    s.Memory,
+		Disk:      s.Disk,
+	}
+}
+
+// sameHost reports whether s and other run on the same host.
+func (s ClusterSpec) sameHost(other ClusterSpec) bool {
+	return reflect.DeepEqual(s.hostSpec(), other.hostSpec())
+}
+
+// ClusterSpecs returns the spec of every cluster on the host: the spec
+// itself if Clusters is empty, else one per entry, each laid over the spec
+// without its Clusters. Maps such as nodes are merged with those of the spec;
+// every other key replaces it.
+func (s ClusterSpec) ClusterSpecs() ([]ClusterSpec, error) {
+	if len(s.Clusters) == 0 {
+		return []ClusterSpec{s}, nil
+	}
+	base := s
+	base.Clusters = nil
+	// Round-trip through JSON so the entries don't share maps and slices
+	// with the spec or each other
+	defaults, err := json.Marshal(base)
+	if err != nil {
+		return nil, err
+	}
+
+	specs := make([]ClusterSpec, 0, len(s.Clusters))
+	var errs []error
+	for i, entry := range s.Clusters {
+		var spec ClusterSpec
+		if err := json.Unmarshal(defaults, &spec); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(entry, &spec); err != nil {
+			errs = append(errs, fmt.Errorf("clusters[%d]: %w", i, err))
+			continue
+		}
+		switch {
+		case len(spec.Clusters) > 0:
+			errs = append(errs, fmt.Errorf("clusters[%d]: clusters cannot be nested", i))
+		case !spec.sameHost(base):
+			errs = append(errs, fmt.Errorf("clusters[%d]: host, vmBackend, autostart, lima, vmName, cpus, memory and disk are shared by every cluster, set them at the top level", i))
+		default:
+			specs = append(specs, spec)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+// validateClusters checks each entry of Clusters and that no two clusters
+// share a name, kubeconfig, merged context, published port or health report
+// file.
+func (s ClusterSpec) validateClusters() []error {
+	if len(s.Clusters) == 0 {
+		return nil
+	}
+	specs, err := s.ClusterSpecs()
+	if err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	owners := map[string]string{}
+	claim := func(what, key, cluster string) {
+		if owner, ok := owners[what+" "+key]; ok {
+			errs = append(errs, fmt.Errorf("clusters %s and %s both use %s %s", owner, cluster, what, key))
+			return
+		}
+		owners[what+" "+key] = cluster
+	}
+	for i, spec := range specs {
+		if err := spec.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("clusters[%d] (%s):\n%w", i, spec.ClusterName, err))
+			continue
+		}
+		claim("clusterName", spec.ClusterName, spec.ClusterName)
+		_, data, err := spec.scriptData()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		claim("kubeconfigPath", data.KubeconfigPath, spec.ClusterName)
+		if data.MergeKubeconfig {
+			claim("contextName", data.ContextName, spec.ClusterName)
+		}
+		for _, p := range append(spec.publishedPorts(), spec.addonContainerPorts()...) {
+			claim("port", p.String(), spec.ClusterName)
+		}
+		for _, path := range []string{spec.HealthReport.JSON, spec.HealthReport.JUnit} {
+			if path != "" {
+				claim("health report file", path, spec.ClusterName)
+			}
+		}
+	}
+	return errs
+}
+
+// NewKindClusters registers every cluster of args (see
+// ClusterSpec.ClusterSpecs) as a KindCluster named after it. The first one
+// registers the host, and the others share it. AdoptLegacyResources only
+// applies to the first.
+func NewKindClusters(ctx *pulumi.Context, args *KindClusterArgs, opts ...pulumi.ResourceOption) ([]*KindCluster, error) {
+	if args == nil {
+		args = &KindClusterArgs{ClusterSpec: DefaultClusterSpec()}
+	}
+	if err := args.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid cluster spec:\n%w", err)
+	}
+	specs, err := args.ClusterSpecs()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(specs))
+	for i, spec := range specs {
+		names[i] = spec.ClusterName
+	}
+
+	var clusters []*KindCluster
+	for i, spec := range specs {
+		clusterArgs := &KindClusterArgs{ClusterSpec: spec}
+		if i == 0 {
+			clusterArgs.AdoptLegacyResources = args.AdoptLegacyResources
+			clusterArgs.HostClusters = names[1:]
+		} else {
+			clusterArgs.SharedHost = clusters[0]
+		}
+		cluster, err := NewKindCluster(ctx, spec.ClusterName, clusterArgs, opts...)
+		if err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters, nil
+}
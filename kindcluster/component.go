@@ -0,0 +1,478 @@
+package kindcluster
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"myk8s-cluster/addons"
+	"myk8s-cluster/host"
+	kubeconfigpkg "myk8s-cluster/kubeconfig"
+
+	"github.com/pulumi/pulumi-command/sdk/go/command/local"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// KindCluster is a kind cluster running in Docker on a host (a Lima VM or the
+// local daemon), together with the Docker context, kubeconfig, CNI, add-ons
+// and autostart around it.
+type KindCluster struct {
+	pulumi.ResourceState
+
+	ClusterName    pulumi.StringOutput `pulumi:"clusterName"`
+	KubeconfigPath pulumi.StringOutput `pulumi:"kubeconfigPath"`
+	// ContextName is the context merged into ~/.kube/config, once it is,
+	// or empty if mergeKubeconfig is false.
+	ContextName pulumi.StringOutput `pulumi:"contextName"`
+	// Kubeconfig is the contents of the cluster kubeconfig, as a secret.
+	Kubeconfig pulumi.StringOutput `pulumi:"kubeconfig"`
+	// Endpoint is the API server URL from the kubeconfig.
+	Endpoint pulumi.StringOutput `pulumi:"endpoint"`
+	// CertificateAuthorityData is the base64-encoded CA certificate of the
+	// API server, from the kubeconfig.
+	CertificateAuthorityData pulumi.StringOutput `pulumi:"certificateAuthorityData"`
+	// Nodes lists the name and internal IP of every node.
+	Nodes pulumi.MapArrayOutput `pulumi:"nodes"`
+	// KubernetesVersion is the kubelet version of the nodes, e.g. v1.31.2.
+	KubernetesVersion pulumi.StringOutput `pulumi:"kubernetesVersion"`
+	// DockerHost is the DOCKER_HOST of the daemon running the nodes, e.g.
+	// the Docker socket of the VM as a unix:// URL.
+	DockerHost pulumi.StringOutput `pulumi:"dockerHost"`
+	// VMName is the name of the VM the nodes run in, e.g. the Lima
+	// instance, or empty on the local Docker daemon.
+	VMName pulumi.StringOutput `pulumi:"vmName"`
+	// Health is PASS, WARN or FAIL from the health checks run after the
+	// update, or empty if they were skipped.
+	Health pulumi.StringOutput `pulumi:"health"`
+	// HealthReport is the result of every health check, as written to
+	// HealthReportSpec.JSON, or empty if they were skipped.
+	HealthReport pulumi.MapOutput `pulumi:"healthReport"`
+	// Addons describes how to use the add-ons, e.g. the push URL of the
+	// registry, keyed by name.
+	Addons pulumi.MapOutput `pulumi:"addons"`
+	// Provider is a Kubernetes provider for deploying into the cluster. The
+	// taints, CNI and add-ons are deployed through it too; depend on the
+	// KindCluster to wait for them.
+	Provider *kubernetes.Provider
+
+	name        string
+	adoptLegacy bool
+	spec        ClusterSpec
+	// hostReady is what a cluster on the host has to wait for, and resize
+	// the step resizing it, if any, for clusters sharing the host.
+	hostReady []pulumi.Resource
+	resize    *local.Command
+}
+
+// KindClusterArgs configures a KindCluster. Start from DefaultClusterSpec and
+// override what you need.
+type KindClusterArgs struct {
+	ClusterSpec
+
+	// AdoptLegacyResources aliases the child resources to the names the
+	// single-file program registered them under, so stacks created before
+	// this component existed are adopted instead of replaced.
+	AdoptLegacyResources bool
+
+	// SharedHost runs the cluster on the host of another KindCluster
+	// instead of registering one. Its spec must select the same host.
+	SharedHost *KindCluster
+	// HostClusters names the clusters sharing this cluster's host, whose
+	// nodes the autostart and resize steps start too.
+	HostClusters []string
+}
+
+// NewKindCluster validates args, runs the preflight checks and registers the
+// VM, cluster and everything around it as children of a new KindCluster
+// component.
+func NewKindCluster(ctx *pulumi.Context, name string, args *KindClusterArgs, opts ...pulumi.ResourceOption) (*KindCluster, error) {
+	if args == nil {
+		args = &KindClusterArgs{ClusterSpec: DefaultClusterSpec()}
+	}
+	if err := args.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid cluster spec:\n%w", err)
+	}
+	if len(args.Clusters) > 0 {
+		return nil, errors.New("the cluster spec lists several clusters, register them with NewKindClusters")
+	}
+	if args.SharedHost != nil && !args.sameHost(args.SharedHost.spec) {
+		return nil, fmt.Errorf("cluster %s cannot share the host of %s, the host keys differ", args.ClusterName, args.SharedHost.spec.ClusterName)
+	}
+	if err := args.preflight(ctx); err != nil {
+		return nil, err
+	}
+
+	c := &KindCluster{name: name, adoptLegacy: args.AdoptLegacyResources, spec: args.ClusterSpec}
+	if err := ctx.RegisterComponentResource("kindcluster:index:KindCluster", name, c, opts...); err != nil {
+		return nil, err
+	}
+	if err := c.build(ctx, args); err != nil {
+		return nil, err
+	}
+	if err := ctx.RegisterResourceOutputs(c, pulumi.Map{
+		"clusterName":              c.ClusterName,
+		"kubeconfigPath":           c.KubeconfigPath,
+		"contextName":              c.ContextName,
+		"kubeconfig":               c.Kubeconfig,
+		"endpoint":                 c.Endpoint,
+		"certificateAuthorityData": c.CertificateAuthorityData,
+		"nodes":                    c.Nodes,
+		"kubernetesVersion":        c.KubernetesVersion,
+		"dockerHost":               c.DockerHost,
+		"vmName":                   c.VMName,
+		"health":                   c.Health,
+		"healthReport":             c.HealthReport,
+		"addons":                   c.Addons,
+	}); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Name is the name of the kind cluster, as in the ClusterName output.
+func (c *KindCluster) Name() string {
+	return c.spec.ClusterName
+}
+
+// childName prefixes a step name with the component name so several
+// clusters can live in one stack.
+func (c *KindCluster) childName(step string) string {
+	return c.name + "-" + step
+}
+
+// opts parents a child resource to the component and, when adopting a legacy
+// stack, aliases it to the unparented step name.
+func (c *KindCluster) opts(step string, opts ...pulumi.ResourceOption) []pulumi.ResourceOption {
+	opts = append(opts, pulumi.Parent(c))
+	if c.adoptLegacy {
+		opts = append(opts, c.legacyAlias(step))
+	}
+	return opts
+}
+
+// legacyAlias aliases a child to a name it was registered under at the root
+// of a legacy stack. It is a no-op unless AdoptLegacyResources is set.
+func (c *KindCluster) legacyAlias(name string) pulumi.ResourceOption {
+	if !c.adoptLegacy {
+		return pulumi.Aliases(nil)
+	}
+	return pulumi.Aliases([]pulumi.Alias{{Name: pulumi.String(name), NoParent: pulumi.Bool(true)}})
+}
+
+// scriptData returns the host of the spec and the data its scripts are
+// rendered with.
+func (s ClusterSpec) scriptData() (host.Host, scriptData, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, scriptData{}, err
+	}
+	h, err := s.hostBackend()
+	if err != nil {
+		return nil, scriptData{}, err
+	}
+	kubeconfigPath := filepath.Join(homeDir, ".kube", s.ClusterName+"-config")
+	if s.KubeconfigPath != "" {
+		kubeconfigPath = s.KubeconfigPath
+		if rest, ok := strings.CutPrefix(kubeconfigPath, "~/"); ok {
+			kubeconfigPath = filepath.Join(homeDir, rest)
+		}
+	}
+	contextName := s.ContextName
+	if contextName == "" {
+		contextName = "kind-" + s.ClusterName
+	}
+	nodes := s.nodes()
+	return h, scriptData{
+		ClusterName:           s.ClusterName,
+		HostClusters:          []string{s.ClusterName},
+		HomeDir:               homeDir,
+		KubeconfigPath:        kubeconfigPath,
+		DefaultKubeconfigPath: filepath.Join(homeDir, ".kube", "config"),
+		MergeKubeconfig:       s.MergeKubeconfig,
+		ContextName:           contextName,
+		MergeRecordPath:       filepath.Join(homeDir, ".local", "share", "myk8s-cluster", "kubeconfig", s.ClusterName+".json"),
+		UseContext:            true,
+		KindConfigPath:        "./kind-config.yaml",
+		CreatedKindConfigPath: filepath.Join(homeDir, ".local", "share", "myk8s-cluster", "kind", s.ClusterName+".yaml"),
+		DockerHost:            h.DockerHost(),
+		DockerContext:         h.DockerContext(),
+		Host:                  h.Name(),
+		NodeDirs:              nodeHostDirs(nodes),
+		ExpectedNodes:         len(nodes),
+	}, nil
+}
+
+// scriptData extends ClusterSpec.scriptData with the clusters sharing the
+// host. Clusters on a shared host write their kind config to a file of their
+// own.
+func (a *KindClusterArgs) scriptData() (host.Host, scriptData, error) {
+	h, data, err := a.ClusterSpec.scriptData()
+	if err != nil {
+		return nil, scriptData{}, err
+	}
+	data.HostClusters = append(data.HostClusters, a.HostClusters...)
+	if a.SharedHost != nil {
+		data.KindConfigPath = "./kind-config-" + a.ClusterName + ".yaml"
+		data.UseContext = false
+	}
+	return h, data, nil
+}
+
+// mergeKubeconfig merges the context of the cluster kubeconfig into
+// ~/.kube/config once it has been exported, on every update, and resolves to
+// the script undoing the merge, which holds the entries to put back.
+// Previews merge nothing and undo the merge recorded last, or nothing.
+func mergeKubeconfig(ctx *pulumi.Context, data scriptData, kubeconfig pulumi.StringOutput) pulumi.StringOutput {
+	return kubeconfig.ApplyT(func(kubeconfig string) (string, error) {
+		var rec *kubeconfigpkg.Record
+		var err error
+		if ctx.DryRun() {
+			rec, err = kubeconfigpkg.ReadRecord(data.MergeRecordPath)
+			if errors.Is(err, fs.ErrNotExist) {
+				return "", nil
+			}
+		} else {
+			rec, err = kubeconfigpkg.Merge(kubeconfigpkg.MergeOptions{
+				From:    data.KubeconfigPath,
+				Data:    []byte(kubeconfig),
+				Into:    data.DefaultKubeconfigPath,
+				Context: data.ContextName,
+				Use:     data.UseContext,
+				Record:  data.MergeRecordPath,
+			})
+		}
+		if err != nil {
+			return "", err
+		}
+		return unmergeKubeconfigScript.Render(unmergeData{Record: *rec, RecordPath: data.MergeRecordPath}), nil
+	}).(pulumi.StringOutput)
+}
+
+// build registers the child resources.
+func (c *KindCluster) build(ctx *pulumi.Context, args *KindClusterArgs) error {
+	spec := args.ClusterSpec
+	clusterName := spec.ClusterName
+
+	// Only create dependencies when truly necessary - host needs dirs and config
+	h, data, err := args.scriptData()
+	if err != nil {
+		return err
+	}
+	kubeconfigPath := data.KubeconfigPath
+
+	// Create mount directories but don't create dependency chain
+	createDirs, err := local.NewCommand(ctx, c.childName("create-dirs"), &local.CommandArgs{
+		Create: pulumi.String(createDirsScript.Render(data)),
+	}, c.opts("create-dirs")...)
+	if err != nil {
+		return err
+	}
+
+	// Render the Kind cluster config from the spec
+	kindConfig, err := spec.kindConfig().Render()
+	if err != nil {
+		return err
+	}
+	kindConfigFile := fileData{Path: data.KindConfigPath, Content: string(kindConfig)}
+	createKindConfig, err := local.NewCommand(ctx, c.childName("create-kind-config"), &local.CommandArgs{
+		Create: pulumi.String(writeFileScript.Render(kindConfigFile)),
+		Delete: pulumi.String(removeFileScript.Render(kindConfigFile)),
+	}, c.opts("create-kind-config")...)
+	if err != nil {
+		return err
+	}
+
+	// A shared host is already there; the cluster only waits for it
+	if shared := args.SharedHost; shared != nil {
+		c.hostReady = append([]pulumi.Resource{createDirs, createKindConfig}, shared.hostReady...)
+		c.resize = shared.resize
+	} else {
+		c.hostReady, c.resize, err = c.buildHost(ctx, spec, h, data, []pulumi.Resource{createDirs, createKindConfig})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create Kind cluster - depends on the host, its autostart and docker context
+	createCluster, err := local.NewCommand(ctx, c.childName("create-kind-cluster"), &local.CommandArgs{
+		Create: pulumi.String(createClusterScript.Render(data)),
+		Delete: pulumi.String(deleteClusterScript.Render(data)),
+	}, c.opts("create-kind-cluster", pulumi.DependsOn(c.hostReady))...)
+	if err != nil {
+		return err
+	}
+
+	// Export kubeconfig first and set it up properly
+	exportKubeconfig, err := local.NewCommand(ctx, c.childName("export-kubeconfig"), &local.CommandArgs{
+		Create: pulumi.String(exportKubeconfigScript.Render(data)),
+		Delete: pulumi.String(removeKubeconfigScript.Render(data)),
+	}, c.opts("export-kubeconfig", pulumi.DependsOn([]pulumi.Resource{createCluster}),
+		// Replacing exports anew to the same path, which deleting afterwards
+		// would remove
+		pulumi.DeleteBeforeReplace(true))...)
+	if err != nil {
+		return err
+	}
+
+	// Add kubeconfig and docker context to shell profiles to make it
+	// persistent. They point at one cluster, the one registering the host.
+	var updateProfiles *local.Command
+	if args.SharedHost == nil {
+		createProfiles, deleteProfiles := shellProfileScripts(data)
+		updateProfiles, err = local.NewCommand(ctx, c.childName("update-shell-profiles"), &local.CommandArgs{
+			Create: pulumi.String(createProfiles),
+			Delete: pulumi.String(deleteProfiles),
+		}, c.opts("update-shell-profiles", pulumi.DependsOn([]pulumi.Resource{exportKubeconfig}))...)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Read the kubeconfig back so downstream programs don't depend on a path
+	// on this machine
+	readKubeconfig, err := local.NewCommand(ctx, c.childName("read-kubeconfig"), &local.CommandArgs{
+		Create:   pulumi.String(readKubeconfigScript.Render(data)),
+		Triggers: pulumi.Array{exportKubeconfig.ID()},
+	}, c.opts("read-kubeconfig", pulumi.DependsOn([]pulumi.Resource{exportKubeconfig}),
+		pulumi.AdditionalSecretOutputs([]string{"stdout"}))...)
+	if err != nil {
+		return err
+	}
+	readClusterInfo, err := local.NewCommand(ctx, c.childName("read-cluster-info"), &local.CommandArgs{
+		Create:   pulumi.String(readClusterInfoScript.Render(data)),
+		Triggers: pulumi.Array{exportKubeconfig.ID()},
+	}, c.opts("read-cluster-info", pulumi.DependsOn([]pulumi.Resource{exportKubeconfig}))...)
+	if err != nil {
+		return err
+	}
+	c.ClusterName = pulumi.String(clusterName).ToStringOutput()
+	c.KubeconfigPath = pulumi.String(kubeconfigPath).ToStringOutput()
+	c.Kubeconfig = pulumi.ToSecret(readKubeconfig.Stdout).(pulumi.StringOutput)
+	c.ContextName = pulumi.String("").ToStringOutput()
+	if data.MergeKubeconfig {
+		// Merging happens in the program; the step keeps what destroying
+		// needs to undo it
+		mergedKubeconfig, err := local.NewCommand(ctx, c.childName("merge-kubeconfig"), &local.CommandArgs{
+			Create: pulumi.String(mergedKubeconfigScript.Render(data)),
+			Delete: mergeKubeconfig(ctx, data, readKubeconfig.Stdout),
+		}, c.opts("merge-kubeconfig", pulumi.DependsOn([]pulumi.Resource{readKubeconfig}))...)
+		if err != nil {
+			return err
+		}
+		c.ContextName = pulumi.Unsecret(mergedKubeconfig.Stdout.ApplyT(func(string) string {
+			return data.ContextName
+		})).(pulumi.StringOutput)
+	}
+	// The server and CA are no secret, unlike the client key next to them
+	c.Endpoint = pulumi.Unsecret(readKubeconfig.Stdout.ApplyT(func(kubeconfig string) (string, error) {
+		cluster, err := kubeconfigCluster(kubeconfig)
+		return cluster.Server, err
+	})).(pulumi.StringOutput)
+	c.CertificateAuthorityData = pulumi.Unsecret(readKubeconfig.Stdout.ApplyT(func(kubeconfig string) (string, error) {
+		cluster, err := kubeconfigCluster(kubeconfig)
+		return cluster.CertificateAuthorityData, err
+	})).(pulumi.StringOutput)
+	c.Nodes = readClusterInfo.Stdout.ApplyT(func(out string) ([]map[string]any, error) {
+		info, err := parseClusterInfo(out)
+		return info.Nodes, err
+	}).(pulumi.MapArrayOutput)
+	c.KubernetesVersion = readClusterInfo.Stdout.ApplyT(func(out string) (string, error) {
+		info, err := parseClusterInfo(out)
+		return info.KubernetesVersion, err
+	}).(pulumi.StringOutput)
+	c.DockerHost = readClusterInfo.Stdout.ApplyT(func(out string) (string, error) {
+		info, err := parseClusterInfo(out)
+		return info.DockerHost, err
+	}).(pulumi.StringOutput)
+	vmName := ""
+	if _, ok := h.(*host.VM); ok {
+		vmName = spec.VMName
+	}
+	c.VMName = pulumi.String(vmName).ToStringOutput()
+
+	// Create K8s provider from the kubeconfig contents. Everything in the
+	// cluster is deployed through it; if the cluster is gone, dropping its
+	// resources from the state is all there is left to delete.
+	k8sProvider, err := kubernetes.NewProvider(ctx, c.childName("k8s-provider"), &kubernetes.ProviderArgs{
+		Kubeconfig:        c.Kubeconfig,
+		DeleteUnreachable: pulumi.Bool(true),
+	}, c.opts("k8s-provider")...)
+	if err != nil {
+		return err
+	}
+	c.Provider = k8sProvider
+	inCluster := []pulumi.ResourceOption{pulumi.Parent(c), pulumi.Provider(k8sProvider)}
+
+	// 1. Taint nodes according to the topology
+	taints, err := c.taintNodes(ctx, taintedNodes(spec.nodes(), clusterName), inCluster)
+	if err != nil {
+		return err
+	}
+
+	// 2. Install the CNI
+	plugin, err := spec.cniPlugin()
+	if err != nil {
+		return err
+	}
+	cniReady, err := c.installCNI(ctx, plugin, data, inCluster)
+	if err != nil {
+		return err
+	}
+
+	// 3. Wait for the CNI to be ready
+	ready := []pulumi.Output{k8sProvider.ID()}
+	for _, taint := range taints {
+		ready = append(ready, taint.ID())
+	}
+	networkReady := cniReady
+	if wait := plugin.WaitScript(); wait != "" {
+		waitForCNI, err := local.NewCommand(ctx, c.childName("wait-for-cni"), &local.CommandArgs{
+			Create: pulumi.String(withKubeconfigScript.Render(data.with(wait))),
+			Environment: pulumi.StringMap{
+				"KUBECONFIG": pulumi.String(kubeconfigPath),
+			},
+		}, c.opts("wait-for-cni", pulumi.DependsOn([]pulumi.Resource{exportKubeconfig}), pulumi.DependsOn(cniReady), c.legacyAlias("wait-for-calico"))...)
+		if err != nil {
+			return err
+		}
+		ready = append(ready, waitForCNI.Stdout)
+		networkReady = append(networkReady, waitForCNI)
+	}
+
+	// 4. Install the add-ons once pods can reach each other
+	enabled, err := spec.addons()
+	if err != nil {
+		return err
+	}
+	firstNodeIP := c.Nodes.ApplyT(func(nodes []map[string]any) string {
+		if len(nodes) == 0 {
+			return ""
+		}
+		ip, _ := nodes[0]["ip"].(string)
+		return ip
+	}).(pulumi.StringOutput)
+	addonsReady, err := c.installAddons(ctx, enabled, addons.Cluster{NodeIP: firstNodeIP}, data, exportKubeconfig,
+		append(slices.Clip(inCluster), pulumi.DependsOn(networkReady)))
+	if err != nil {
+		return err
+	}
+	ready = append(ready, addonsReady...)
+	c.Addons = addonOutputs(enabled).ToMapOutput()
+
+	// Check the cluster on every update once everything above is up,
+	// including after the host has been resized
+	if updateProfiles != nil {
+		ready = append(ready, updateProfiles.ID())
+	}
+	if c.resize != nil {
+		ready = append(ready, c.resize.Stdout)
+	}
+	c.HealthReport = c.healthCheck(ctx, spec.HealthCheck, spec.HealthReport, healthChecks(h, plugin, enabled, data), ready...)
+	c.Health = healthStatus(c.HealthReport)
+	return nil
+}
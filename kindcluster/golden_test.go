@@ -0,0 +1,267 @@
+package kindcluster
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"myk8s-cluster/addons"
+	"myk8s-cluster/host"
+	"myk8s-cluster/kindconfig"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// goldenConfigs is the matrix of specs whose scripts and config files are
+// kept in testdata/golden/<name>. Each starts from DefaultClusterSpec.
+var goldenConfigs = []struct {
+	name string
+	spec func(s *ClusterSpec)
+}{
+	{"lima-calico", func(s *ClusterSpec) {
+		s.Autostart = "systemd"
+	}},
+	{"lima-rootful-calico-operator-ha", func(s *ClusterSpec) {
+		s.Autostart = "launchd"
+		s.Lima.Docker = "rootful"
+		s.CNI = "calico-operator"
+		s.ControlPlanes = 3
+		s.Workers = 2
+		s.ExtraPortMappings = []kindconfig.PortMapping{{ContainerPort: 80, HostPort: 8080}}
+		s.Nodes = map[string]NodeSpec{"worker2": {Labels: map[string]string{"tier": "storage"}}}
+	}},
+	{"colima-cilium", func(s *ClusterSpec) {
+		s.VMBackend = "colima"
+		s.CNI = "cilium"
+	}},
+	{"podman-flannel", func(s *ClusterSpec) {
+		s.VMBackend = "podman"
+		s.Autostart = "systemd"
+		s.CNI = "flannel"
+	}},
+	{"multipass-kindnet", func(s *ClusterSpec) {
+		s.VMBackend = "multipass"
+		s.CNI = "kindnet"
+	}},
+	{"docker-calico", func(s *ClusterSpec) {
+		s.Host = "docker"
+		s.Autostart = "systemd"
+	}},
+	{"rootless-docker-no-cni", func(s *ClusterSpec) {
+		s.Host = "rootless-docker"
+		s.CNI = "none"
+		s.Workers = 0
+		s.ControlPlane.Taints = nil
+	}},
+	{"docker-addons", func(s *ClusterSpec) {
+		s.Host = "docker"
+		s.Addons = map[string]addons.Settings{
+			"cert-manager":   {},
+			"ingress-nginx":  {HTTPPort: 8080},
+			"metallb":        {Addresses: []string{"172.18.255.200-172.18.255.250"}},
+			"metrics-server": {},
+			"registry":       {},
+		}
+	}},
+	{"lima-hub-spoke", func(s *ClusterSpec) {
+		s.Autostart = "systemd"
+		s.Clusters = []json.RawMessage{
+			json.RawMessage(`{"clusterName": "hub", "workers": 1}`),
+			json.RawMessage(`{"clusterName": "spoke", "cni": "kindnet", "workers": 0, "kubeconfigPath": "~/.kube/spoke.yaml",
+				"networking": {"podSubnet": "10.245.0.0/16", "serviceSubnet": "10.97.0.0/16"}}`),
+		}
+	}},
+}
+
+func TestGolden(t *testing.T) {
+	// Fixed so the paths in the scripts don't change between machines
+	t.Setenv("HOME", "/home/dev")
+	for _, gc := range goldenConfigs {
+		t.Run(gc.name, func(t *testing.T) {
+			spec := DefaultClusterSpec()
+			spec.Host = "vm"
+			spec.Autostart = "none"
+			spec.Memory = 2
+			spec.Preflight = PreflightOff
+			spec.HealthCheck = HealthCheckOff
+			spec.Lima.VMType = "vz"
+			gc.spec(&spec)
+			checkGolden(t, filepath.Join("testdata", "golden", gc.name), renderFiles(t, spec))
+		})
+	}
+}
+
+// renderFiles returns every script the cluster's commands run, named
+// <step>.<create|update|delete>.sh, the health check scripts named
+// health-<check>.sh, kind-config.yaml and the host config file. Steps and
+// kind configs of the clusters after the first are prefixed with their name.
+func renderFiles(t *testing.T, spec ClusterSpec) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+
+	specs, err := spec.ClusterSpecs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range specs {
+		kindConfig, err := s.kindConfig().Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := "kind-config.yaml"
+		if i > 0 {
+			name = "kind-config-" + s.ClusterName + ".yaml"
+		}
+		files[name] = string(kindConfig)
+	}
+	first := specs[0]
+
+	h, err := first.hostBackend()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cf, ok := h.(host.ConfigFile); ok {
+		path, content, err := cf.ConfigFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path != "" {
+			files[filepath.Base(path)] = string(content)
+		}
+	}
+
+	data := scriptData{ClusterName: first.ClusterName, DockerHost: h.DockerHost()}
+	for _, c := range commandChecks(h, data) {
+		files["health-"+c.name+".sh"] = c.script
+	}
+
+	m := &scriptMocks{prefix: first.ClusterName + "-", files: files}
+	err = pulumi.RunErr(func(ctx *pulumi.Context) error {
+		_, err := NewKindClusters(ctx, &KindClusterArgs{ClusterSpec: spec})
+		return err
+	}, pulumi.WithMocks("myk8s-cluster", "golden", m),
+		// A preview, so the program merges nothing into the kubeconfig
+		func(info *pulumi.RunInfo) { info.DryRun = true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// scriptMocks collects the scripts of every local.Command registered.
+type scriptMocks struct {
+	prefix string
+
+	mu    sync.Mutex
+	files map[string]string
+}
+
+func (m *scriptMocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	outputs := args.Inputs.Copy()
+	if args.TypeToken != "command:local:Command" {
+		return args.Name + "-id", outputs, nil
+	}
+
+	step := strings.TrimPrefix(args.Name, m.prefix)
+	m.mu.Lock()
+	for _, key := range []string{"create", "update", "delete"} {
+		// Previews render no unmerge script before the first merge
+		if v, ok := args.Inputs[resource.PropertyKey(key)]; ok && v.IsString() && v.StringValue() != "" {
+			m.files[step+"."+key+".sh"] = v.StringValue()
+		}
+	}
+	m.mu.Unlock()
+
+	stdout := ""
+	if strings.HasSuffix(step, "read-kubeconfig") {
+		stdout = "clusters:\n- cluster:\n    server: https://127.0.0.1:6443\n"
+	}
+	outputs["stdout"] = resource.NewStringProperty(stdout)
+	return args.Name + "-id", outputs, nil
+}
+
+func (m *scriptMocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	return args.Args, nil
+}
+
+// checkGolden compares files with the contents of dir, or rewrites dir with
+// them when -update is set.
+func checkGolden(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	if *update {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("%v (run go test ./kindcluster -update to create it)", err)
+	}
+	want := map[string]bool{}
+	for _, e := range entries {
+		want[e.Name()] = true
+		if _, ok := files[e.Name()]; !ok {
+			t.Errorf("%s is no longer generated", filepath.Join(dir, e.Name()))
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if !want[name] {
+			t.Errorf("%s is generated but has no golden file", path)
+			continue
+		}
+		golden, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := files[name]; got != string(golden) {
+			t.Errorf("%s differs from the generated file, first at %s", path, firstDiff(string(golden), got))
+		}
+	}
+	if t.Failed() {
+		t.Log("if the change is intended, run go test ./kindcluster -update and review the diff")
+	}
+}
+
+// firstDiff describes the first line that differs between want and got.
+func firstDiff(want, got string) string {
+	w, g := strings.Split(want, "\n"), strings.Split(got, "\n")
+	for i := 0; i < len(w) || i < len(g); i++ {
+		var wl, gl string
+		if i < len(w) {
+			wl = w[i]
+		}
+		if i < len(g) {
+			gl = g[i]
+		}
+		if i >= len(w) || i >= len(g) || wl != gl {
+			return "line " + strconv.Itoa(i+1) + ":\n  want: " + strconv.Quote(wl) + "\n   got: " + strconv.Quote(gl)
+		}
+	}
+	return "the end"
+}
@@ -0,0 +1,483 @@
+package kindcluster
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"myk8s-cluster/addons"
+	"myk8s-cluster/preflight"
+)
+
+// stubs are the default bodies of the fake binaries. They keep the state of
+// the VMs and clusters they create as files under $STUB_DIR/state, so the
+// scripts see what they did earlier in the test.
+var stubs = map[string]string{
+	"limactl": `
+		case "$1" in
+		list)
+			for f in "$state"/vm-*; do
+				[ -f "$f" ] && echo "${f##*/vm-} $(cat "$f")"
+			done
+			;;
+		create)
+			for arg; do
+				case "$arg" in --name) next=1 ;; *) [ -n "$next" ] && echo Stopped > "$state/vm-$arg" && next= ;; esac
+			done
+			;;
+		start) eval "vm=\${$#}"; echo Running > "$state/vm-$vm" ;;
+		stop) eval "vm=\${$#}"; [ -f "$state/vm-$vm" ] && echo Stopped > "$state/vm-$vm" ;;
+		delete) eval "vm=\${$#}"; rm -f "$state/vm-$vm" ;;
+		esac
+	`,
+	"kind": `
+		case "$1 $2" in
+		"get clusters")
+			for f in "$state"/kind-*; do
+				[ -f "$f" ] && echo "${f##*/kind-}"
+			done
+			;;
+		"get nodes") [ -f "$state/kind-$4" ] && cat "$state/kind-$4" ;;
+		"create cluster") touch "$state/kind-$4" ;;
+		"delete cluster") rm -f "$state/kind-$4" ;;
+		"export kubeconfig")
+			printf 'clusters:\n- cluster:\n    server: https://localhost:6443\n' > "$6"
+			;;
+		esac
+	`,
+	"kubectl": `
+		case "$*" in
+		"config current-context") echo kind-myk8s ;;
+		esac
+	`,
+	"docker": `
+		case "$1 $2" in
+		"context show") echo default ;;
+		esac
+	`,
+	"launchctl": ``,
+	// The scripts poll with sleep; the tests don't need to wait
+	"sleep": ``,
+}
+
+// fakeBins runs generated scripts with recording stubs of limactl, kind,
+// kubectl, docker and launchctl first on PATH.
+type fakeBins struct {
+	t *testing.T
+	// dir holds the stubs, their call log and state.
+	dir string
+	// Home is HOME for the scripts and Work their working directory.
+	Home, Work string
+}
+
+// newFakeBins installs the default stubs and points HOME at an empty
+// directory, so scripts rendered afterwards use it.
+func newFakeBins(t *testing.T) *fakeBins {
+	t.Helper()
+	f := &fakeBins{t: t, dir: t.TempDir(), Home: t.TempDir(), Work: t.TempDir()}
+	t.Setenv("HOME", f.Home)
+	if err := os.MkdirAll(filepath.Join(f.dir, "state"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range stubs {
+		f.stub(name, body)
+	}
+	return f
+}
+
+// stub replaces the behavior of a fake binary. body is run by sh with the
+// binary's arguments and $state set to the state directory.
+func (f *fakeBins) stub(name, body string) {
+	f.t.Helper()
+	script := "#!/bin/sh\n" +
+		`echo "` + name + ` $*" >> "$STUB_DIR/calls"` + "\n" +
+		`state="$STUB_DIR/state"` + "\n" +
+		body + "\n"
+	if err := os.WriteFile(filepath.Join(f.dir, name), []byte(script), 0o755); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+// run executes script the way local.Command does, with env added to the
+// environment, and returns its combined output.
+func (f *fakeBins) run(script string, env ...string) (string, error) {
+	f.t.Helper()
+	cmd := exec.Command("/bin/sh", "-c", script)
+	cmd.Dir = f.Work
+	cmd.Env = append([]string{
+		"HOME=" + f.Home,
+		"PATH=" + f.dir + string(os.PathListSeparator) + os.Getenv("PATH"),
+		"STUB_DIR=" + f.dir,
+	}, env...)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+// mustRun runs script and fails the test if it fails.
+func (f *fakeBins) mustRun(script string, env ...string) string {
+	f.t.Helper()
+	out, err := f.run(script, env...)
+	if err != nil {
+		f.t.Fatalf("script failed: %v\n%s", err, out)
+	}
+	return out
+}
+
+// calls returns the recorded invocations of the named binaries, in order,
+// and clears the log.
+func (f *fakeBins) calls(names ...string) []string {
+	f.t.Helper()
+	path := filepath.Join(f.dir, "calls")
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		f.t.Fatal(err)
+	}
+	_ = os.Remove(path)
+
+	var calls []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		for _, name := range names {
+			if strings.HasPrefix(line, name+" ") {
+				calls = append(calls, strings.TrimSpace(line))
+			}
+		}
+	}
+	return calls
+}
+
+// expectCalls fails the test unless got is want.
+func expectCalls(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("calls:\n got %q\nwant %q", got, want)
+	}
+}
+
+// harnessSpec is a Lima VM cluster with the given autostart.
+func harnessSpec(autostart string) ClusterSpec {
+	spec := DefaultClusterSpec()
+	spec.Host = "vm"
+	spec.Autostart = autostart
+	spec.Memory = 2
+	spec.Preflight = PreflightOff
+	spec.HealthCheck = HealthCheckOff
+	spec.Lima.VMType = "vz"
+	return spec
+}
+
+func TestHarnessLimaLifecycle(t *testing.T) {
+	f := newFakeBins(t)
+	scripts := renderFiles(t, harnessSpec("none"))
+	kubeconfig := filepath.Join(f.Home, ".kube", "myk8s-config")
+
+	for _, step := range []string{"host-config", "host", "setup-docker", "create-kind-config", "create-kind-cluster", "export-kubeconfig"} {
+		f.mustRun(scripts[step+".create.sh"])
+	}
+	expectCalls(t, f.calls("limactl", "kind", "docker"),
+		"limactl list --format {{.Name}} {{.Status}}",
+		"limactl create --tty=false --name myk8s-docker ./lima-myk8s-docker.yaml",
+		"limactl start --tty=false myk8s-docker",
+		"limactl list --format {{.Name}} {{.Status}}",
+		"docker context rm lima-myk8s-docker",
+		"docker context create lima-myk8s-docker --docker host=unix://"+f.Home+"/.lima/myk8s-docker/sock/docker.sock",
+		"docker context use lima-myk8s-docker",
+		"docker context show",
+		"kind get clusters",
+		"kind create cluster --name myk8s --config ./kind-config.yaml",
+		"kind get clusters",
+		"kind export kubeconfig --name myk8s --kubeconfig "+kubeconfig,
+	)
+	for _, file := range []string{"lima-myk8s-docker.yaml", "kind-config.yaml"} {
+		if _, err := os.Stat(filepath.Join(f.Work, file)); err != nil {
+			t.Error(err)
+		}
+	}
+	if data, err := os.ReadFile(kubeconfig); err != nil || !strings.Contains(string(data), "https://127.0.0.1:6443") {
+		t.Errorf("kubeconfig not rewritten to 127.0.0.1: %q, %v", data, err)
+	}
+	created := filepath.Join(f.Home, ".local", "share", "myk8s-cluster", "kind", "myk8s.yaml")
+	if _, err := os.Stat(created); err != nil {
+		t.Errorf("kind config of the cluster not kept: %v", err)
+	}
+
+	// Running the create scripts again leaves the VM and cluster alone
+	f.mustRun(scripts["host.create.sh"])
+	f.mustRun(scripts["create-kind-cluster.create.sh"])
+	expectCalls(t, f.calls("limactl", "kind"),
+		"limactl list --format {{.Name}} {{.Status}}",
+		"limactl list --format {{.Name}} {{.Status}}",
+		"kind get clusters",
+		"kind get clusters",
+	)
+
+	for _, step := range []string{"export-kubeconfig", "create-kind-cluster", "host", "host-config"} {
+		f.mustRun(scripts[step+".delete.sh"])
+	}
+	if _, err := os.Stat(kubeconfig); !os.IsNotExist(err) {
+		t.Errorf("kubeconfig left behind: %v", err)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("kind config of the cluster left behind: %v", err)
+	}
+	expectCalls(t, f.calls("limactl", "kind"),
+		"kind get clusters",
+		"kind delete cluster --name myk8s",
+		"kind delete cluster --name myk8s",
+		"limactl stop myk8s-docker",
+		"limactl list --format {{.Name}} {{.Status}}",
+		"limactl delete --force myk8s-docker",
+	)
+	if _, err := os.Stat(filepath.Join(f.Work, "lima-myk8s-docker.yaml")); !os.IsNotExist(err) {
+		t.Errorf("host config left behind: %v", err)
+	}
+}
+
+func TestHarnessVMNeverRunning(t *testing.T) {
+	f := newFakeBins(t)
+	scripts := renderFiles(t, harnessSpec("none"))
+	f.stub("limactl", `[ "$1" = list ] && echo "myk8s-docker Starting"; exit 0`)
+
+	out, err := f.run(scripts["host.create.sh"])
+	if err == nil {
+		t.Fatalf("host create succeeded with the VM never running:\n%s", out)
+	}
+	if !strings.Contains(out, "ERROR: VM failed to start after 30 attempts") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	if sleeps := len(f.calls("sleep")); sleeps != 30 {
+		t.Errorf("waited %d times, want 30", sleeps)
+	}
+}
+
+func TestHarnessKindCreateFails(t *testing.T) {
+	f := newFakeBins(t)
+	scripts := renderFiles(t, harnessSpec("none"))
+	f.stub("kind", `[ "$1" = create ] && { echo "node image pull failed" >&2; exit 1; }; exit 0`)
+
+	out, err := f.run(scripts["create-kind-cluster.create.sh"])
+	if err == nil {
+		t.Fatalf("create-kind-cluster succeeded with kind create failing:\n%s", out)
+	}
+	for _, want := range []string{"node image pull failed", "ERROR: Failed to create or verify Kind cluster"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+	expectCalls(t, f.calls("kind"),
+		"kind get clusters",
+		"kind create cluster --name myk8s --config ./kind-config.yaml",
+		"kind get clusters",
+	)
+}
+
+func TestHarnessResize(t *testing.T) {
+	f := newFakeBins(t)
+	spec := harnessSpec("none")
+	spec.Disk = 600
+	scripts := renderFiles(t, spec)
+	f.mustRun(scripts["host.create.sh"])
+	f.calls()
+
+	f.mustRun(scripts["resize-host.update.sh"], "PULUMI_COMMAND_STDOUT=cpus=8 memory=2 disk=500")
+	expectCalls(t, f.calls("limactl"),
+		"limactl stop myk8s-docker",
+		"limactl list --format {{.Name}} {{.Status}}",
+		"limactl edit --tty=false --cpus 8 --memory 2 --disk 600 myk8s-docker",
+		"limactl list --format {{.Name}} {{.Status}}",
+		"limactl start --tty=false myk8s-docker",
+		"limactl list --format {{.Name}} {{.Status}}",
+	)
+
+	out, err := f.run(scripts["resize-host.update.sh"], "PULUMI_COMMAND_STDOUT=cpus=8 memory=2 disk=700")
+	if err == nil || !strings.Contains(out, "disk cannot shrink from 700GB to 600GB") {
+		t.Errorf("shrinking the disk was not rejected: %v\n%s", err, out)
+	}
+	expectCalls(t, f.calls("limactl"))
+}
+
+func TestHarnessLaunchdAutostart(t *testing.T) {
+	f := newFakeBins(t)
+	scripts := renderFiles(t, harnessSpec("launchd"))
+	plist := filepath.Join(f.Home, "Library", "LaunchAgents", "myk8s-cluster.myk8s.plist")
+	login := filepath.Join(f.Home, ".local", "share", "myk8s-cluster", "myk8s-cluster.myk8s.sh")
+
+	f.mustRun(scripts["autostart.create.sh"])
+	expectCalls(t, f.calls("launchctl"),
+		"launchctl unload "+plist,
+		"launchctl load "+plist,
+	)
+	for _, path := range []string{plist, login} {
+		if _, err := os.Stat(path); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// The login script starts the stopped VM and the kind nodes in it
+	f.stub("limactl", stubs["limactl"])
+	if err := os.WriteFile(filepath.Join(f.dir, "state", "vm-myk8s-docker"), []byte("Stopped\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f.stub("docker", `[ "$1" = ps ] && echo "abc123 def456"; exit 0`)
+	f.mustRun("/bin/sh " + login)
+	expectCalls(t, f.calls("limactl", "docker"),
+		"limactl list --format {{.Name}} {{.Status}}",
+		"limactl start --tty=false myk8s-docker",
+		"docker info",
+		"docker ps -aq --filter label=io.x-k8s.kind.cluster=myk8s",
+		"docker start abc123 def456",
+	)
+
+	f.mustRun(scripts["autostart.delete.sh"])
+	expectCalls(t, f.calls("launchctl"), "launchctl unload "+plist)
+	for _, path := range []string{plist, login} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s left behind: %v", path, err)
+		}
+	}
+
+	// Installing fails early when a binary the login script needs is missing
+	if _, err := exec.LookPath("limactl"); err == nil {
+		t.Skip("limactl is installed, so removing the stub does not hide it")
+	}
+	if err := os.Remove(filepath.Join(f.dir, "limactl")); err != nil {
+		t.Fatal(err)
+	}
+	out, err := f.run(scripts["autostart.create.sh"])
+	if err == nil || !strings.Contains(out, "ERROR: limactl not found in PATH") {
+		t.Errorf("install without limactl: %v\n%s", err, out)
+	}
+}
+
+func TestHarnessPreflight(t *testing.T) {
+	f := newFakeBins(t)
+	t.Setenv("PATH", f.dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("STUB_DIR", f.dir)
+	t.Chdir(f.Work)
+	spec := harnessSpec("none")
+	spec.Disk = 1
+
+	checks := func() []preflight.Check {
+		args := &KindClusterArgs{ClusterSpec: spec}
+		h, data, err := args.scriptData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		plugin, err := spec.cniPlugin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return args.preflightChecks(h, plugin, data)
+	}
+	if err := preflight.Run(context.Background(), checks()...); err != nil {
+		t.Fatalf("preflight failed on a clean machine:\n%v", err)
+	}
+
+	state := filepath.Join(f.dir, "state")
+	if err := os.WriteFile(filepath.Join(state, "vm-myk8s-docker"), []byte("Broken\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(state, "kind-myk8s"), []byte("myk8s-control-plane\nmyk8s-worker\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f.stub("limactl", `[ "$1" = --version ] && echo "limactl version 0.9.0"; `+stubs["limactl"])
+
+	err := preflight.Run(context.Background(), checks()...)
+	if err == nil {
+		t.Fatal("preflight passed with a broken VM and a cluster in the way")
+	}
+	for _, want := range []string{
+		"binaries: limactl 0.9.0 is older than the required 1.0.0",
+		"host: lima VM myk8s-docker is Broken, repair or delete it first",
+		"cluster: kind cluster myk8s already exists with 2 nodes instead of 4",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not contain %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestHarnessPreflightAddons(t *testing.T) {
+	f := newFakeBins(t)
+	t.Setenv("PATH", f.dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("STUB_DIR", f.dir)
+	t.Chdir(f.Work)
+	spec := harnessSpec("none")
+	spec.Disk = 1
+
+	// The cluster runs, created with the config rendered before and after
+	// ingress-nginx was added to the spec
+	nodes := "myk8s-control-plane\nmyk8s-worker\nmyk8s-worker2\nmyk8s-worker3\n"
+	if err := os.WriteFile(filepath.Join(f.dir, "state", "kind-myk8s"), []byte(nodes), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	created := func(spec ClusterSpec) string {
+		data, err := spec.kindConfig().Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, sd, err := spec.scriptData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Dir(sd.CreatedKindConfigPath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(sd.CreatedKindConfigPath, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return sd.CreatedKindConfigPath
+	}
+	path := created(spec)
+	spec.Addons = map[string]addons.Settings{"ingress-nginx": {}}
+	check := spec.clusterCheck(func() scriptData {
+		_, data, err := spec.scriptData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}())
+
+	err := preflight.Run(context.Background(), check)
+	want := "kind cluster myk8s was created without the ports, labels or containerd config of ingress-nginx, recreate the cluster to enable ingress-nginx"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("preflight = %v, want %q", err, want)
+	}
+
+	if created(spec) != path {
+		t.Fatal("the kept kind config moved")
+	}
+	if err := preflight.Run(context.Background(), check); err != nil {
+		t.Errorf("preflight failed on a cluster created with ingress-nginx:\n%v", err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := preflight.Run(context.Background(), check); err != nil {
+		t.Errorf("preflight failed on a cluster created without a kept config:\n%v", err)
+	}
+}
+
+func TestHarnessWriteFile(t *testing.T) {
+	f := newFakeBins(t)
+	// A line that would end a heredoc, and no trailing newline
+	content := "patch: |\nEOF\necho injected > injected\n$(touch substituted) 'quoted'\nEOF"
+	path := filepath.Join(f.Work, "it's.yaml")
+	f.mustRun(writeFileScript.Render(fileData{Path: path, Content: content}))
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Errorf("content:\n got %q\nwant %q", data, content)
+	}
+	for _, name := range []string{"injected", "substituted"} {
+		if _, err := os.Stat(filepath.Join(f.Work, name)); err == nil {
+			t.Errorf("content ran as shell: %s exists", name)
+		}
+	}
+}
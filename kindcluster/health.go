@@ -0,0 +1,196 @@
+package kindcluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"myk8s-cluster/addons"
+	"myk8s-cluster/cni"
+	"myk8s-cluster/healthcheck"
+	"myk8s-cluster/host"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Values of the `healthCheck` config key.
+const (
+	// HealthCheckFail fails the update when a critical check fails.
+	HealthCheckFail = "fail"
+	// HealthCheckWarn only reports failed checks.
+	HealthCheckWarn = "warn"
+	// HealthCheckOff skips the checks.
+	HealthCheckOff = "off"
+)
+
+// healthChecks are the checks run against the cluster after every update:
+// the host, Docker and kind from this machine, then the API server, nodes,
+// system pods, CNI, CoreDNS and add-ons through the Kubernetes API.
+func healthChecks(h host.Host, plugin cni.CNI, enabled []addons.Addon, data scriptData) []healthcheck.Check {
+	var checks []healthcheck.Check
+	for _, c := range commandChecks(h, data) {
+		checks = append(checks, healthcheck.Command(c.name, healthcheck.Critical, c.script))
+	}
+	// Nodes stay NotReady until a CNI is installed, which cni.None leaves
+	// to the user
+	nodes := healthcheck.Critical
+	if _, ok := plugin.(cni.None); ok {
+		nodes = healthcheck.Warning
+	}
+	checks = append(checks,
+		healthcheck.APIServer(),
+		healthcheck.NodesReady(data.ExpectedNodes, nodes),
+		healthcheck.SystemPods(),
+	)
+	if check := plugin.HealthCheck(); check != nil {
+		checks = append(checks, check)
+	}
+	checks = append(checks, healthcheck.CoreDNS())
+	for _, addon := range enabled {
+		checks = append(checks, addon.HealthCheck())
+	}
+	return checks
+}
+
+// HealthChecks returns the checks run after every update of the cluster of
+// the spec, to run them again outside of an update.
+func (s ClusterSpec) HealthChecks() ([]healthcheck.Check, error) {
+	h, data, err := s.scriptData()
+	if err != nil {
+		return nil, err
+	}
+	plugin, err := s.cniPlugin()
+	if err != nil {
+		return nil, err
+	}
+	enabled, err := s.addons()
+	if err != nil {
+		return nil, err
+	}
+	return healthChecks(h, plugin, enabled, data), nil
+}
+
+// commandCheck is a health check script run on this machine.
+type commandCheck struct {
+	name, script string
+}
+
+// commandChecks check what the Kubernetes API can't see: that the host is
+// running, and Docker and the kind cluster on it are reachable.
+func commandChecks(h host.Host, data scriptData) []commandCheck {
+	return []commandCheck{
+		{"host", withDockerHostScript.Render(data.with(h.HealthCheckScript()))},
+		{"docker", dockerHealthCheckScript.Render(data)},
+		{"kind-cluster", kindHealthCheckScript.Render(data)},
+	}
+}
+
+// HealthReportSpec names files the health report is written to after every
+// update, relative to the project directory. Empty paths aren't written.
+type HealthReportSpec struct {
+	// JSON is the report as in the healthReport output.
+	JSON string `json:"json"`
+	// JUnit is the report as a JUnit XML test suite, one test per check.
+	JUnit string `json:"junit"`
+}
+
+// healthCheck runs checks once ready has resolved, logs the report on the
+// component, writes it to the files in files and, in HealthCheckFail mode,
+// fails the update if a critical check failed. It resolves to the report as
+// marshalled by healthcheck.Report. Previews don't run the checks and resolve
+// to an empty report.
+func (c *KindCluster) healthCheck(ctx *pulumi.Context, mode string, files HealthReportSpec, checks []healthcheck.Check, ready ...pulumi.Output) pulumi.MapOutput {
+	if mode == HealthCheckOff {
+		return pulumi.Map{}.ToMapOutput()
+	}
+	inputs := append([]any{c.Kubeconfig, c.ClusterName}, toAny(ready)...)
+	report := pulumi.All(inputs...).ApplyTWithContext(ctx.Context(), func(goctx context.Context, args []any) (map[string]any, error) {
+		if ctx.DryRun() {
+			return map[string]any{}, nil
+		}
+		client, err := healthcheck.NewClient([]byte(args[0].(string)))
+		if err != nil {
+			return nil, err
+		}
+		report := healthcheck.Runner{Client: client}.Run(goctx, checks...)
+
+		msg := fmt.Sprintf("Health checks %s:\n%s", report.Status(), report)
+		if report.Status() == healthcheck.Pass {
+			_ = ctx.Log.Info(msg, &pulumi.LogArgs{Resource: c})
+		} else {
+			_ = ctx.Log.Warn(msg, &pulumi.LogArgs{Resource: c})
+		}
+		if err := writeHealthReport(files, args[1].(string), report); err != nil {
+			return nil, err
+		}
+		if mode == HealthCheckFail {
+			if err := report.Err(); err != nil {
+				return nil, err
+			}
+		}
+		return healthReportValue(report)
+	}).(pulumi.MapOutput)
+	return pulumi.Unsecret(report).(pulumi.MapOutput)
+}
+
+// healthStatus is the overall status of a report from healthCheck, or "".
+func healthStatus(report pulumi.MapOutput) pulumi.StringOutput {
+	return report.ApplyT(func(report map[string]any) string {
+		status, _ := report["status"].(string)
+		return status
+	}).(pulumi.StringOutput)
+}
+
+// healthReportValue converts report to plain values for a stack output.
+func healthReportValue(report healthcheck.Report) (map[string]any, error) {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	var value map[string]any
+	return value, json.Unmarshal(data, &value)
+}
+
+// writeHealthReport writes report to the files in files, named after the
+// cluster in the JUnit suite.
+func writeHealthReport(files HealthReportSpec, clusterName string, report healthcheck.Report) error {
+	if files.JSON != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeReportFile(files.JSON, append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	if files.JUnit != "" {
+		data, err := report.JUnit(clusterName)
+		if err != nil {
+			return err
+		}
+		if err := writeReportFile(files.JUnit, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeReportFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("writing health report: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("writing health report: %w", err)
+	}
+	return nil
+}
+
+func toAny(outputs []pulumi.Output) []any {
+	values := make([]any, len(outputs))
+	for i, o := range outputs {
+		values[i] = o
+	}
+	return values
+}
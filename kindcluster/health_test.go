@@ -0,0 +1,35 @@
+package kindcluster
+
+import (
+	"testing"
+
+	"myk8s-cluster/healthcheck"
+)
+
+func TestHealthChecksNodes(t *testing.T) {
+	newFakeBins(t)
+	for cni, want := range map[string]healthcheck.Severity{
+		"calico": healthcheck.Critical,
+		// Nodes stay NotReady without a CNI, which must not fail the update
+		"none": healthcheck.Warning,
+	} {
+		spec := harnessSpec("none")
+		spec.CNI = cni
+		checks, err := spec.HealthChecks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var nodes healthcheck.Check
+		for _, check := range checks {
+			if check.Name() == "nodes" {
+				nodes = check
+			}
+		}
+		if nodes == nil {
+			t.Fatalf("cni %s: no nodes check", cni)
+		}
+		if nodes.Severity() != want {
+			t.Errorf("cni %s: nodes check is %s, want %s", cni, nodes.Severity(), want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package kindcluster
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"myk8s-cluster/autostart"
+	"myk8s-cluster/host"
+
+	"github.com/pulumi/pulumi-command/sdk/go/command/local"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// hostBackend returns the host selected by the `host` config key.
+func (s ClusterSpec) hostBackend() (host.Host, error) {
+	return host.New(s.Host, host.Options{
+		VMBackend: s.VMBackend,
+		VM: host.VMOptions{
+			Name:   s.VMName,
+			CPUs:   s.CPUs,
+			Memory: s.Memory,
+			Disk:   s.Disk,
+			Lima:   s.limaInstance(),
+		},
+	})
+}
+
+// buildHost registers the host itself, its Docker context and its autostart
+// entry, and returns the resources the kind cluster has to wait for. For
+// resizable hosts it also returns the resize step, which the cluster has to
+// be verified again after.
+func (c *KindCluster) buildHost(ctx *pulumi.Context, spec ClusterSpec, h host.Host, data scriptData, dependsOn []pulumi.Resource) ([]pulumi.Resource, *local.Command, error) {
+	// Hosts created from a config file get it written, and kept in the
+	// state, by a resource of their own
+	if cf, ok := h.(host.ConfigFile); ok {
+		path, content, err := cf.ConfigFile()
+		if err != nil {
+			return nil, nil, err
+		}
+		if path != "" {
+			file := fileData{Path: path, Content: string(content)}
+			hostConfig, err := local.NewCommand(ctx, c.childName("host-config"), &local.CommandArgs{
+				Create: pulumi.String(writeFileScript.Render(file)),
+				Delete: pulumi.String(removeFileScript.Render(file)),
+			}, c.opts("host-config")...)
+			if err != nil {
+				return nil, nil, err
+			}
+			dependsOn = append(dependsOn, hostConfig)
+		}
+	}
+
+	args := &local.CommandArgs{
+		Create: pulumi.String(withDockerHostScript.Render(data.with(h.ProvisionScript()))),
+	}
+	if teardown := h.TeardownScript(); teardown != "" {
+		args.Delete = pulumi.String(hostTeardownScript.Render(data.with(teardown)))
+	}
+	provision, err := local.NewCommand(ctx, c.childName("host"), args,
+		c.opts("host", pulumi.DependsOn(dependsOn), c.legacyAlias("lima-vm"))...)
+	if err != nil {
+		return nil, nil, err
+	}
+	ready := []pulumi.Resource{provision}
+
+	// Apply size changes to the existing host in place
+	var resize *local.Command
+	if r, ok := h.(host.Resizer); ok {
+		resize, err = c.resizeHost(ctx, spec, data.with(r.ResizeScript()), provision)
+		if err != nil {
+			return nil, nil, err
+		}
+		ready = append(ready, resize)
+	}
+
+	// These operations depend only on the host and can run in parallel
+	autostart, err := c.autostart(ctx, spec, h, data, provision)
+	if err != nil {
+		return nil, nil, err
+	}
+	if autostart != nil {
+		ready = append(ready, autostart)
+	}
+
+	// Setup Docker context - only depends on the host
+	if data.DockerContext != "" {
+		setupDocker, err := local.NewCommand(ctx, c.childName("setup-docker"), &local.CommandArgs{
+			Create: pulumi.String(setupDockerScript.Render(data)),
+			Delete: pulumi.String(removeDockerContextScript.Render(data)),
+		}, c.opts("setup-docker", pulumi.DependsOn([]pulumi.Resource{provision}))...)
+		if err != nil {
+			return nil, nil, err
+		}
+		ready = append(ready, setupDocker)
+	}
+	return ready, resize, nil
+}
+
+// resizeHost registers a step recording the host size. Changing cpus,
+// memory or disk updates it, which runs data.Script to resize the host and
+// starts the kind nodes again. Shrinking the disk is rejected, as no backend
+// supports it.
+func (c *KindCluster) resizeHost(ctx *pulumi.Context, spec ClusterSpec, data scriptData, dependsOn pulumi.Resource) (*local.Command, error) {
+	resize := resizeData{
+		scriptData: data,
+		Disk:       spec.Disk,
+		Size:       fmt.Sprintf("cpus=%d memory=%d disk=%d", spec.CPUs, spec.Memory, spec.Disk),
+	}
+	return local.NewCommand(ctx, c.childName("resize-host"), &local.CommandArgs{
+		Create: pulumi.String(recordSizeScript.Render(resize)),
+		Update: pulumi.String(resizeHostScript.Render(resize)),
+	}, c.opts("resize-host", pulumi.DependsOn([]pulumi.Resource{dependsOn}))...)
+}
+
+// autostart installs the login agent bringing the host and the kind nodes
+// back after a reboot.
+func (c *KindCluster) autostart(ctx *pulumi.Context, spec ClusterSpec, h host.Host, data scriptData, dependsOn pulumi.Resource) (*local.Command, error) {
+	a, err := spec.autostart(h, data)
+	if err != nil || a == nil {
+		return nil, err
+	}
+	return local.NewCommand(ctx, c.childName("autostart"), &local.CommandArgs{
+		Create: pulumi.String(a.InstallScript()),
+		Delete: pulumi.String(a.UninstallScript()),
+	}, c.opts("autostart", pulumi.DependsOn([]pulumi.Resource{dependsOn}))...)
+}
+
+// autostart returns the implementation selected by the `autostart` config
+// key, or nil if disabled.
+func (s ClusterSpec) autostart(h host.Host, data scriptData) (autostart.Autostart, error) {
+	name := s.Autostart
+	if name == autostart.Auto {
+		name = autostart.ForOS(runtime.GOOS)
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return autostart.New(name, autostart.Options{
+		Label:    "myk8s-cluster." + s.ClusterName,
+		HomeDir:  homeDir,
+		Script:   startNodesScript.Render(data.with(h.AutostartScript())),
+		Binaries: h.Binaries(),
+	})
+}
@@ -0,0 +1,50 @@
+package kindcluster
+
+import (
+	"slices"
+
+	"myk8s-cluster/kindconfig"
+)
+
+// kindConfig builds the kind cluster config for the spec, with the changes
+// the add-ons need.
+func (s ClusterSpec) kindConfig() kindconfig.Cluster {
+	networking := s.Networking
+	// An unknown cni is reported by Validate
+	plugin, err := s.cniPlugin()
+	networking.DisableDefaultCNI = err != nil || plugin.DisableDefaultCNI()
+
+	var nodes []kindconfig.Node
+	for i, n := range s.nodes() {
+		node := kindconfig.Node{
+			Role:   n.Role,
+			Image:  n.Image,
+			Labels: n.Labels,
+			ExtraMounts: append([]kindconfig.Mount{
+				{HostPath: n.DiskDir, ContainerPath: "/var/lib/disk1"},
+			}, n.ExtraMounts...),
+		}
+		if i == 0 {
+			node.ExtraPortMappings = slices.Clone(s.ExtraPortMappings)
+		}
+		nodes = append(nodes, node)
+	}
+
+	config := kindconfig.Cluster{
+		Kind:                    kindconfig.Kind,
+		APIVersion:              kindconfig.APIVersion,
+		Networking:              networking,
+		Nodes:                   nodes,
+		KubeadmConfigPatches:    s.KubeadmConfigPatches,
+		ContainerdConfigPatches: slices.Clone(s.ContainerdConfigPatches),
+	}
+	// Invalid add-ons and topologies without nodes are reported by Validate
+	enabled, err := s.addons()
+	if err != nil || len(nodes) == 0 {
+		return config
+	}
+	for _, addon := range enabled {
+		addon.KindConfig(&config)
+	}
+	return config
+}
@@ -0,0 +1,67 @@
+package kindcluster
+
+import (
+	"fmt"
+
+	"myk8s-cluster/limaconfig"
+)
+
+// LimaSpec customises the Lima instance of the lima VM backend. It is laid
+// over limaconfig.Docker: vmType, arch, images, mounts, mountType, dns,
+// rosetta, containerd and hostResolver replace the defaults when set, while
+// provision, probes, portForwards and networks are appended to them.
+type LimaSpec struct {
+	limaconfig.Instance
+	// Docker is "rootless" (the default, as in template:docker) or
+	// "rootful".
+	Docker string `json:"docker"`
+}
+
+// limaInstance returns the Lima instance config for the spec. Its size is
+// filled in by the lima backend from cpus, memory and disk.
+func (s ClusterSpec) limaInstance() limaconfig.Instance {
+	o := s.Lima.Instance
+	inst := limaconfig.Docker(s.Lima.Docker == "rootful")
+	if o.VMType != "" {
+		inst.VMType = o.VMType
+	}
+	if o.Arch != "" {
+		inst.Arch = o.Arch
+	}
+	if len(o.Images) > 0 {
+		inst.Images = o.Images
+	}
+	if len(o.Mounts) > 0 {
+		inst.Mounts = o.Mounts
+	}
+	if o.MountType != "" {
+		inst.MountType = o.MountType
+	}
+	if len(o.DNS) > 0 {
+		inst.DNS = o.DNS
+	}
+	if o.Rosetta != nil {
+		inst.Rosetta = o.Rosetta
+	}
+	if o.Containerd != nil {
+		inst.Containerd = o.Containerd
+	}
+	if o.HostResolver != nil {
+		inst.HostResolver = o.HostResolver
+	}
+	inst.Provision = append(inst.Provision, o.Provision...)
+	inst.Probes = append(inst.Probes, o.Probes...)
+	inst.PortForwards = append(inst.PortForwards, o.PortForwards...)
+	inst.Networks = append(inst.Networks, o.Networks...)
+	inst.Env = o.Env
+	return inst
+}
+
+func (l LimaSpec) validate() error {
+	switch l.Docker {
+	case "", "rootless", "rootful":
+		return nil
+	default:
+		return fmt.Errorf("lima.docker must be rootless or rootful, got %q", l.Docker)
+	}
+}
@@ -0,0 +1,59 @@
+package kindcluster
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"myk8s-cluster/cni"
+)
+
+// kindDefaultPodSubnet is the pod CIDR kind uses when networking.podSubnet
+// is not set.
+const kindDefaultPodSubnet = "10.244.0.0/16"
+
+// cniPlugin returns the CNI selected by the `cni` config key.
+func (s ClusterSpec) cniPlugin() (cni.CNI, error) {
+	manifest, err := s.calicoManifest()
+	if err != nil {
+		return nil, err
+	}
+	podSubnet := s.Networking.PodSubnet
+	if podSubnet == "" {
+		podSubnet = kindDefaultPodSubnet
+	}
+	return cni.New(s.CNI, cni.Options{
+		CalicoVersion:  s.CalicoVersion,
+		CalicoManifest: manifest,
+		CiliumVersion:  s.CiliumVersion,
+		FlannelVersion: s.FlannelVersion,
+		PodSubnet:      podSubnet,
+	})
+}
+
+// calicoManifest resolves spec.CalicoManifest to a file a ConfigGroup reads:
+// the upstream URL by default, or a local file.
+func (s ClusterSpec) calicoManifest() (string, error) {
+	if s.CalicoManifest == "" {
+		return fmt.Sprintf("https://raw.githubusercontent.com/projectcalico/calico/%s/manifests/calico.yaml", s.CalicoVersion), nil
+	}
+	return filepath.Abs(s.CalicoManifest)
+}
+
+func (s ClusterSpec) validateNetwork() []error {
+	var errs []error
+	if _, err := s.cniPlugin(); err != nil {
+		errs = append(errs, err)
+	}
+	// The Flannel manifest has the kind default subnet in its net-conf.json
+	if s.CNI == "flannel" && s.Networking.PodSubnet != "" && s.Networking.PodSubnet != kindDefaultPodSubnet {
+		errs = append(errs, fmt.Errorf("networking.podSubnet must be %s with cni flannel, got %q", kindDefaultPodSubnet, s.Networking.PodSubnet))
+	}
+	if s.CNI != "calico" || s.CalicoManifest == "" {
+		return errs
+	}
+	if _, err := os.Stat(s.CalicoManifest); err != nil {
+		errs = append(errs, fmt.Errorf("calicoManifest: %w", err))
+	}
+	return errs
+}
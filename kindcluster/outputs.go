@@ -0,0 +1,66 @@
+package kindcluster
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// kubeconfigClusterInfo is the cluster entry of a kubeconfig.
+type kubeconfigClusterInfo struct {
+	Server                   string `yaml:"server"`
+	CertificateAuthorityData string `yaml:"certificate-authority-data"`
+}
+
+// kubeconfigCluster returns the first cluster in a kubeconfig.
+func kubeconfigCluster(kubeconfig string) (kubeconfigClusterInfo, error) {
+	var cfg struct {
+		Clusters []struct {
+			Cluster kubeconfigClusterInfo `yaml:"cluster"`
+		} `yaml:"clusters"`
+	}
+	if err := yaml.Unmarshal([]byte(kubeconfig), &cfg); err != nil {
+		return kubeconfigClusterInfo{}, err
+	}
+	if len(cfg.Clusters) == 0 {
+		return kubeconfigClusterInfo{}, errors.New("kubeconfig has no clusters")
+	}
+	return cfg.Clusters[0].Cluster, nil
+}
+
+// clusterInfo is what read-cluster-info reports about the running cluster.
+type clusterInfo struct {
+	DockerHost string
+	// KubernetesVersion is the kubelet version of the first node; kind
+	// nodes all run the same image.
+	KubernetesVersion string
+	// Nodes has the name and first internal IP of each node.
+	Nodes []map[string]any
+}
+
+// parseClusterInfo parses the output of readClusterInfoScript.
+func parseClusterInfo(out string) (clusterInfo, error) {
+	info := clusterInfo{Nodes: []map[string]any{}}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case fields[0] == "docker-host" && len(fields) == 2:
+			info.DockerHost = fields[1]
+		case fields[0] == "node" && len(fields) >= 3:
+			ip := ""
+			if len(fields) > 3 {
+				ip = fields[3]
+			}
+			if info.KubernetesVersion == "" {
+				info.KubernetesVersion = fields[2]
+			}
+			info.Nodes = append(info.Nodes, map[string]any{"name": fields[1], "ip": ip})
+		default:
+			return clusterInfo{}, fmt.Errorf("unexpected cluster info line %q", line)
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,161 @@
+package kindcluster
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"myk8s-cluster/cni"
+	"myk8s-cluster/host"
+	"myk8s-cluster/kindconfig"
+	kubeconfigpkg "myk8s-cluster/kubeconfig"
+	"myk8s-cluster/preflight"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Values of the `preflight` config key.
+const (
+	// PreflightFail fails before any resource is registered if a check
+	// fails.
+	PreflightFail = "fail"
+	// PreflightWarn only logs failed checks.
+	PreflightWarn = "warn"
+	// PreflightOff skips the checks.
+	PreflightOff = "off"
+)
+
+// preflight runs the preflight checks of the cluster unless they are off.
+func (a *KindClusterArgs) preflight(ctx *pulumi.Context) error {
+	if a.Preflight == PreflightOff {
+		return nil
+	}
+	h, data, err := a.scriptData()
+	if err != nil {
+		return err
+	}
+	plugin, err := a.cniPlugin()
+	if err != nil {
+		return err
+	}
+	err = preflight.Run(ctx.Context(), a.preflightChecks(h, plugin, data)...)
+	if err == nil || a.Preflight == PreflightFail {
+		return err
+	}
+	_ = ctx.Log.Warn(err.Error(), nil)
+	return nil
+}
+
+// preflightChecks check the binaries the scripts run, the memory and disk
+// of VM hosts, that the host can be provisioned, that no cluster of the same
+// name is in the way or ports it publishes taken, and the paths written.
+// Clusters on a shared host leave the host to the cluster registering it.
+func (a *KindClusterArgs) preflightChecks(h host.Host, plugin cni.CNI, data scriptData) []preflight.Check {
+	binaries := append([]string{"kind", "kubectl"}, h.Binaries()...)
+	if b, ok := plugin.(cni.Binaries); ok {
+		binaries = append(binaries, b.Binaries()...)
+	}
+	checks := []preflight.Check{preflight.Binaries(binaries...)}
+	if a.SharedHost == nil {
+		if _, ok := h.(*host.VM); ok {
+			checks = append(checks, preflight.Memory(a.Memory), preflight.Disk(data.HomeDir, a.Disk))
+		}
+		if p, ok := h.(host.Preflighter); ok {
+			checks = append(checks, preflight.Script("host", withDockerHostScript.Render(data.with(p.PreflightScript()))))
+		}
+	}
+	return append(checks, a.clusterCheck(data), preflight.Writable(a.writablePaths(h, data)...))
+}
+
+// clusterCheck fails if a kind cluster of the same name already runs on the
+// host with another number of nodes, or was created without the kind config
+// an add-on needs, which create-kind-cluster would keep as it is. If none
+// runs, the ports the cluster publishes must be free.
+func (s ClusterSpec) clusterCheck(data scriptData) preflight.Check {
+	ports := preflight.Ports(s.publishedPorts()...)
+	return preflight.Func("cluster", func(ctx context.Context) error {
+		out, err := preflight.Output(ctx, kindNodesScript.Render(data))
+		if err != nil {
+			return err
+		}
+		nodes := strings.Fields(out)
+		switch {
+		case len(nodes) == 0:
+			return ports.Run(ctx)
+		case len(nodes) != data.ExpectedNodes:
+			return fmt.Errorf("kind cluster %s already exists with %d nodes instead of %d, delete it with `kind delete cluster --name %s`",
+				data.ClusterName, len(nodes), data.ExpectedNodes, data.ClusterName)
+		}
+		missing, err := s.kindConfigMissing(data.CreatedKindConfigPath)
+		if err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("kind cluster %s was created without the ports, labels or containerd config of %s, recreate the cluster to enable %s",
+				data.ClusterName, strings.Join(missing, ", "), strings.Join(missing, ", "))
+		}
+		return nil
+	})
+}
+
+// publishedPorts are the API server port, if fixed, and the host ports the
+// nodes publish, from extraPortMappings and the add-ons.
+func (s ClusterSpec) publishedPorts() []preflight.Port {
+	var ports []preflight.Port
+	if s.Networking.APIServerPort != 0 {
+		address := s.Networking.APIServerAddress
+		if address == "" {
+			address = "127.0.0.1"
+		}
+		ports = append(ports, preflight.Port{Address: address, Port: s.Networking.APIServerPort, Protocol: "tcp"})
+	}
+	var mappings []kindconfig.PortMapping
+	for _, n := range s.kindConfig().Nodes {
+		mappings = append(mappings, n.ExtraPortMappings...)
+	}
+	for _, m := range mappings {
+		protocol := strings.ToLower(m.Protocol)
+		if m.HostPort == 0 || protocol == "sctp" {
+			continue
+		}
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		address := m.ListenAddress
+		if address == "" {
+			address = "0.0.0.0"
+		}
+		ports = append(ports, preflight.Port{Address: address, Port: m.HostPort, Protocol: protocol})
+	}
+	return ports
+}
+
+// writablePaths are the files the steps write on this machine.
+func (s ClusterSpec) writablePaths(h host.Host, data scriptData) []string {
+	paths := []string{
+		data.KubeconfigPath,
+		data.DefaultKubeconfigPath,
+		data.KindConfigPath,
+		filepath.Join(data.HomeDir, "bin", "use-k8s.sh"),
+	}
+	for _, profile := range shellProfiles {
+		paths = append(paths, filepath.Join(data.HomeDir, profile))
+	}
+	if data.MergeKubeconfig {
+		paths = append(paths, data.DefaultKubeconfigPath+kubeconfigpkg.BackupSuffix, data.MergeRecordPath)
+	}
+	paths = append(paths, data.CreatedKindConfigPath)
+	paths = append(paths, data.NodeDirs...)
+	if c, ok := h.(host.ConfigFile); ok {
+		if path, _, err := c.ConfigFile(); err == nil && path != "" {
+			paths = append(paths, path)
+		}
+	}
+	for _, path := range []string{s.HealthReport.JSON, s.HealthReport.JUnit} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
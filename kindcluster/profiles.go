@@ -0,0 +1,93 @@
+package kindcluster
+
+import "myk8s-cluster/script"
+
+// shellProfiles are the rc files the environment exports are added to.
+var shellProfiles = []string{".zshrc", ".bashrc"}
+
+// profileData is what the shell profile script templates are rendered with.
+type profileData struct {
+	scriptData
+	Profiles []string
+	// Lines are the export lines added to every profile.
+	Lines []string
+	// Activation is the content of ~/bin/use-k8s.sh.
+	Activation string
+}
+
+var activationScript = script.New("use-k8s", `#!/bin/bash
+export KUBECONFIG={{quote .KubeconfigPath}}
+{{- if .DockerContext}}
+export DOCKER_CONTEXT={{quote .DockerContext}}
+{{- end}}
+echo Kubernetes context set to {{quote .ClusterName}}
+{{- if .DockerContext}}
+echo Docker context set to {{quote .DockerContext}}
+{{- end}}
+kubectl cluster-info
+{{- if .DockerContext}}
+docker context show
+{{- end}}`)
+
+var updateProfilesScript = script.New("update-shell-profiles", `
+		echo "Updating shell profiles..."
+		for profile in {{range .Profiles}} "$HOME"/{{quote .}}{{end}}; do
+			for line in {{quoteAll .Lines}}; do
+				if ! grep -qxF -- "$line" "$profile" 2>/dev/null; then
+					echo "$line" >> "$profile"
+					echo "Updated $profile with ${line%%=*}"
+				fi
+			done
+		done
+
+		mkdir -p ~/bin
+		printf '%s\n' {{quote .Activation}} > ~/bin/use-k8s.sh
+		chmod +x ~/bin/use-k8s.sh
+		echo "Created activation script at ~/bin/use-k8s.sh"
+	`)
+
+var removeProfilesScript = script.New("remove-shell-profiles", `
+		for profile in {{range .Profiles}} "$HOME"/{{quote .}}{{end}}; do
+			[ -f "$profile" ] || continue
+			for line in {{quoteAll .Lines}}; do
+				grep -vxF -- "$line" "$profile" > "$profile.tmp" || true
+				cat "$profile.tmp" > "$profile"
+				rm -f "$profile.tmp"
+			done
+		done
+
+		# Remove activation script
+		rm -f ~/bin/use-k8s.sh 2>/dev/null || true
+	`)
+
+// shellProfileScripts returns the Create and Delete scripts that add the
+// cluster environment to the shell profiles and write ~/bin/use-k8s.sh.
+// DOCKER_CONTEXT is left out when the host has no docker context.
+func shellProfileScripts(data scriptData) (string, string) {
+	p := profileData{
+		scriptData: data,
+		Profiles:   shellProfiles,
+		Lines:      shellEnv(data),
+	}
+	p.Activation = activationScript.Render(p)
+	return updateProfilesScript.Render(p), removeProfilesScript.Render(p)
+}
+
+// shellEnv is the cluster environment as export lines.
+func shellEnv(data scriptData) []string {
+	lines := []string{"export KUBECONFIG=" + script.Quote(data.KubeconfigPath)}
+	if data.DockerContext != "" {
+		lines = append(lines, "export DOCKER_CONTEXT="+script.Quote(data.DockerContext))
+	}
+	return lines
+}
+
+// ShellEnv returns the export lines the cluster of the spec adds to the shell
+// profiles: KUBECONFIG and, if the host has one, DOCKER_CONTEXT.
+func (s ClusterSpec) ShellEnv() ([]string, error) {
+	_, data, err := s.scriptData()
+	if err != nil {
+		return nil, err
+	}
+	return shellEnv(data), nil
+}
@@ -0,0 +1,83 @@
+package kindcluster
+
+import (
+	"myk8s-cluster/cni"
+
+	"github.com/pulumi/pulumi-command/sdk/go/command/local"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// taintNodes sets the taints of every tainted node. The API server treats
+// a node's taints as one list, so the patch replaces those kind set, and
+// deleting it removes them all.
+func (c *KindCluster) taintNodes(ctx *pulumi.Context, nodes []nodeTaints, opts []pulumi.ResourceOption) ([]*corev1.NodePatch, error) {
+	var patches []*corev1.NodePatch
+	for _, n := range nodes {
+		var taints corev1.TaintPatchArray
+		for _, taint := range n.Taints {
+			key, value, effect := parseTaint(taint)
+			taints = append(taints, &corev1.TaintPatchArgs{
+				Key:    pulumi.String(key),
+				Value:  pulumi.String(value),
+				Effect: pulumi.String(effect),
+			})
+		}
+		patch, err := corev1.NewNodePatch(ctx, c.childName("taint-"+n.Name), &corev1.NodePatchArgs{
+			Metadata: &metav1.ObjectMetaPatchArgs{
+				Name:        pulumi.String(n.Node),
+				Annotations: pulumi.StringMap{"pulumi.com/patchForce": pulumi.String("true")},
+			},
+			Spec: &corev1.NodeSpecPatchArgs{Taints: taints},
+		}, opts...)
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, patch)
+	}
+	return patches, nil
+}
+
+// installCNI registers the resources of the CNI plugin and returns them.
+//
+// Earlier versions installed the plugin with an install-cni command whose
+// delete uninstalled it. Removing the command would run that delete after
+// the resources took the plugin over, so it is kept without one. Its update
+// runs once on those stacks, before the resources are created, and migrates
+// plugins that need it.
+func (c *KindCluster) installCNI(ctx *pulumi.Context, plugin cni.CNI, data scriptData, opts []pulumi.ResourceOption) ([]pulumi.Resource, error) {
+	legacyCommand := func() (*local.Command, error) {
+		const noop = `echo "The CNI is installed as Kubernetes resources"`
+		update := noop
+		if m, ok := plugin.(cni.Migrator); ok {
+			update = withKubeconfigScript.Render(data.with(m.MigrateScript()))
+		}
+		return local.NewCommand(ctx, c.childName("install-cni"), &local.CommandArgs{
+			Create: pulumi.String(noop),
+			Update: pulumi.String(update),
+			Environment: pulumi.StringMap{
+				"KUBECONFIG": pulumi.String(data.KubeconfigPath),
+			},
+		}, c.opts("install-cni", c.legacyAlias("install-calico"))...)
+	}
+
+	var legacy *local.Command
+	if _, ok := plugin.(cni.Migrator); ok {
+		var err error
+		if legacy, err = legacyCommand(); err != nil {
+			return nil, err
+		}
+		opts = append(opts, pulumi.DependsOn([]pulumi.Resource{legacy}))
+	}
+	resources, err := plugin.Install(ctx, c.childName("cni"), opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(resources) > 0 && legacy == nil {
+		if _, err := legacyCommand(); err != nil {
+			return nil, err
+		}
+	}
+	return resources, nil
+}
@@ -0,0 +1,334 @@
+package kindcluster
+
+import (
+	kubeconfigpkg "myk8s-cluster/kubeconfig"
+	"myk8s-cluster/script"
+)
+
+// scriptData is what the script templates of the component are rendered
+// with. DockerHost and Script are shell snippets inserted as they are; every
+// other value goes through quote.
+type scriptData struct {
+	ClusterName string
+	// HostClusters are the clusters on the host, this one first.
+	HostClusters          []string
+	HomeDir               string
+	KubeconfigPath        string
+	DefaultKubeconfigPath string
+	// MergeKubeconfig merges ContextName into DefaultKubeconfigPath,
+	// recorded at MergeRecordPath, and makes it current if UseContext. The
+	// program merges, see mergeKubeconfig, and unmergeKubeconfigScript
+	// undoes it.
+	MergeKubeconfig bool
+	ContextName     string
+	MergeRecordPath string
+	UseContext      bool
+	KindConfigPath  string
+	// CreatedKindConfigPath is where the kind config the cluster was
+	// created with is kept, see kindConfigMissing.
+	CreatedKindConfigPath string
+	DockerHost            string
+	DockerContext         string
+	Host                  string
+	NodeDirs              []string
+	ExpectedNodes         int
+
+	// Script is the snippet wrapped by the template being rendered.
+	Script string
+}
+
+// with returns a copy of d wrapping snippet.
+func (d scriptData) with(snippet string) scriptData {
+	d.Script = snippet
+	return d
+}
+
+var createDirsScript = script.New("create-dirs", `mkdir -p {{quoteAll .NodeDirs}}`)
+
+// fileData is what writeFileScript is rendered with.
+type fileData struct {
+	Path    string
+	Content string
+}
+
+// The content is quoted rather than put in a heredoc, which a line of it
+// could end early.
+var writeFileScript = script.New("write-file", `printf '%s' {{quote .Content}} > {{quote .Path}}`)
+
+var removeFileScript = script.New("remove-file", `rm -f {{quote .Path}}`)
+
+var hostTeardownScript = script.New("host-teardown", `
+			# First, try to delete the Kind clusters that might be running on this host
+			for cluster_name in {{quoteAll .HostClusters}}; do
+				DOCKER_HOST={{.DockerHost}} kind delete cluster --name "$cluster_name" 2>/dev/null || true
+			done
+			{{.Script}}
+		`)
+
+var setupDockerScript = script.New("setup-docker", `
+				docker_context={{quote .DockerContext}}
+				docker context rm "$docker_context" 2>/dev/null || true
+				docker context create "$docker_context" --docker host={{.DockerHost}} || true
+				docker context use "$docker_context" || true
+				echo "Current Docker context: $(docker context show)"
+			`)
+
+var removeDockerContextScript = script.New("remove-docker-context", `
+				# Reset Docker context to default during cleanup
+				docker context use default 2>/dev/null || true
+				docker context rm {{quote .DockerContext}} 2>/dev/null || true
+			`)
+
+// resizeData is what resizeHostScript is rendered with.
+type resizeData struct {
+	scriptData
+	Disk int
+	Size string
+}
+
+var resizeHostScript = script.New("resize-host", `
+			# The previous size is the last line of the previous run
+			previous_disk=$(printf '%s\n' "$PULUMI_COMMAND_STDOUT" | sed -n 's/.*disk=\([0-9]*\).*/\1/p' | tail -n 1)
+			if [ -n "$previous_disk" ] && [ {{.Disk}} -lt "$previous_disk" ]; then
+				echo "ERROR: disk cannot shrink from ${previous_disk}GB to {{.Disk}}GB, destroy and recreate the host to use a smaller disk" >&2
+				exit 1
+			fi
+			{{.Script}}
+
+			# Bring the kind nodes back and wait for them before the health checks
+			export DOCKER_HOST={{.DockerHost}}
+			for cluster_name in {{quoteAll .HostClusters}}; do
+				if kind get clusters 2>/dev/null | grep -qxF "$cluster_name"; then
+					docker start $(docker ps -aq --filter label=io.x-k8s.kind.cluster="$cluster_name") >/dev/null
+					docker exec "$cluster_name-control-plane" kubectl --kubeconfig /etc/kubernetes/admin.conf \
+						wait --for=condition=Ready nodes --all --timeout=300s
+				fi
+			done
+			echo {{quote .Size}}
+		`)
+
+var recordSizeScript = script.New("record-size", `echo {{quote .Size}}`)
+
+// startNodesScript runs at login: it starts the host, waits for Docker and
+// starts the kind node containers, which Docker does not restart by itself
+// after a reboot.
+var startNodesScript = script.New("start-nodes", `{{.Script}}
+		export DOCKER_HOST={{.DockerHost}}
+		attempt=0
+		until docker info >/dev/null 2>&1; do
+			if [ $attempt -ge 60 ]; then
+				echo "ERROR: Docker is not reachable at $DOCKER_HOST"
+				exit 1
+			fi
+			sleep 2
+			attempt=$((attempt+1))
+		done
+		for cluster_name in {{quoteAll .HostClusters}}; do
+			nodes=$(docker ps -aq --filter label=io.x-k8s.kind.cluster="$cluster_name")
+			if [ -n "$nodes" ]; then
+				docker start $nodes
+			fi
+		done
+	`)
+
+var createClusterScript = script.New("create-kind-cluster", `
+			export DOCKER_HOST={{.DockerHost}}
+			cluster_name={{quote .ClusterName}}
+
+			# Check if cluster already exists
+			if kind get clusters | grep -qxF "$cluster_name"; then
+				echo "Kind cluster '$cluster_name' already exists"
+			else
+				echo "Creating Kind cluster '$cluster_name'..."
+				kind create cluster --name "$cluster_name" --config {{quote .KindConfigPath}}
+				# Kind only reads its config now; keep it to tell later changes apart
+				mkdir -p "$(dirname {{quote .CreatedKindConfigPath}})"
+				cp {{quote .KindConfigPath}} {{quote .CreatedKindConfigPath}}
+			fi
+
+			# Verify cluster is accessible
+			if kind get clusters | grep -qxF "$cluster_name"; then
+				echo "Kind cluster '$cluster_name' verified successfully"
+			else
+				echo "ERROR: Failed to create or verify Kind cluster"
+				exit 1
+			fi
+		`)
+
+var deleteClusterScript = script.New("delete-kind-cluster", `
+			export DOCKER_HOST={{.DockerHost}}
+			cluster_name={{quote .ClusterName}}
+
+			echo "Deleting Kind cluster '$cluster_name'..."
+			# Delete the Kind cluster
+			if kind get clusters 2>/dev/null | grep -qxF "$cluster_name"; then
+				kind delete cluster --name "$cluster_name"
+				echo "Kind cluster '$cluster_name' deleted successfully"
+			else
+				echo "Kind cluster '$cluster_name' not found, skipping deletion"
+			fi
+			rm -f {{quote .CreatedKindConfigPath}}
+		`)
+
+var exportKubeconfigScript = script.New("export-kubeconfig", `
+			cluster_name={{quote .ClusterName}}
+			kubeconfig={{quote .KubeconfigPath}}
+
+			# Create .kube directory if it doesn't exist
+			mkdir -p {{quote .HomeDir}}/.kube
+
+			# Export kubeconfig to a specific file
+			echo "Exporting kubeconfig to $kubeconfig"
+			DOCKER_HOST={{.DockerHost}} kind export kubeconfig --name "$cluster_name" --kubeconfig "$kubeconfig"
+
+			# Make sure the kubeconfig file is accessible
+			chmod 600 "$kubeconfig"
+
+			# Export the KUBECONFIG environment variable for this session
+			export KUBECONFIG="$kubeconfig"
+
+			# Fix the kubeconfig if it has localhost references (often causes connection issues)
+			# Replace localhost with 127.0.0.1 which is more reliable
+			sed -i.bak 's|server: https://localhost:|server: https://127.0.0.1:|g' "$kubeconfig"
+
+			# Automatically set kubectl context to the new cluster
+			kubectl config use-context "kind-$cluster_name"
+
+			# Verify the kubeconfig is valid
+			echo "Testing kubectl configuration..."
+			kubectl version --client || true
+			echo "Current kubectl context: $(kubectl config current-context)"
+		`)
+
+var removeKubeconfigScript = script.New("remove-kubeconfig", `
+			kubeconfig={{quote .KubeconfigPath}}
+			default_kubeconfig={{quote .DefaultKubeconfigPath}}
+
+			# Remove the kubeconfig file during cleanup
+			rm -f "$kubeconfig" 2>/dev/null || true
+			rm -f "$kubeconfig.bak" 2>/dev/null || true
+
+			# Remove the symlink earlier versions made, if it points to our config
+			if [ -L "$default_kubeconfig" ] && [ "$(readlink "$default_kubeconfig")" = "$kubeconfig" ]; then
+				rm -f "$default_kubeconfig" 2>/dev/null || true
+			fi
+		`)
+
+var mergedKubeconfigScript = script.New("merged-kubeconfig",
+	`echo "Context "{{quote .ContextName}}" is merged into "{{quote .DefaultKubeconfigPath}}`)
+
+// unmergeData is what unmergeKubeconfigScript is rendered with.
+type unmergeData struct {
+	kubeconfigpkg.Record
+	// RecordPath is where Merge kept Record.
+	RecordPath string
+}
+
+// unmergeKubeconfigScript does what kubeconfig.Unmerge does with the record
+// it is rendered with, using kubectl: destroying runs no program, and
+// kindctl may not be around.
+var unmergeKubeconfigScript = script.New("unmerge-kubeconfig", `
+			into={{quote .Into}}
+			context={{quote .Context}}
+			record={{quote .RecordPath}}
+			if [ ! -f "$into" ]; then
+				rm -f "$record"
+				exit 0
+			fi
+			if ! command -v kubectl >/dev/null 2>&1; then
+				echo "Warning: kubectl not found, $context stays in $into"
+				exit 0
+			fi
+
+			# The entries as merged, and those the merge replaced
+			merged="$into.myk8s-cluster.merged"
+			previous="$into.myk8s-cluster.previous"
+			trap 'rm -f "$merged" "$previous" "$into.myk8s-cluster.tmp"' EXIT
+			(umask 077
+			printf '%s' {{quote .Merged}} > "$merged"
+			printf '%s' {{quote .Previous}} > "$previous")
+			previous_context=$(KUBECONFIG="$previous" kubectl config current-context 2>/dev/null || true)
+			KUBECONFIG="$previous" kubectl config unset current-context >/dev/null
+			current_context=$(KUBECONFIG="$into" kubectl config current-context 2>/dev/null || true)
+
+			# entry FILE KIND NAME FIELD prints an entry of a kubeconfig, or
+			# nothing if it has none of that kind
+			entry() {
+				KUBECONFIG="$1" kubectl config view --raw -o jsonpath="{.$2[?(@.name==\"$3\")].$4}" 2>/dev/null || true
+			}
+			# undo KIND NAME FIELD COMMAND removes an entry still as merged, and
+			# notes if the merge replaced one; entries changed since stay
+			restore=
+			undo() {
+				now=$(entry "$into" "$1" "$2" "$3")
+				if [ -n "$now" ] && [ "$now" = "$(entry "$merged" "$1" "$2" "$3")" ]; then
+					KUBECONFIG="$into" kubectl config "$4" "$2" >/dev/null
+					if [ -n "$(entry "$previous" "$1" "$2" "$3")" ]; then
+						restore=1
+					fi
+				fi
+			}
+			undo contexts "$context" context delete-context
+			undo clusters {{quote .Cluster}} cluster delete-cluster
+			undo users {{quote .User}} user delete-user
+
+			# Put back the entries the merge replaced: kubectl merges the files
+			# entry by entry, the first having one winning. It writes paths in
+			# them out in full.
+			if [ -n "$restore" ]; then
+				KUBECONFIG="$into:$previous" kubectl config view --raw > "$into.myk8s-cluster.tmp"
+				mv "$into.myk8s-cluster.tmp" "$into"
+			fi
+			if [ "$current_context" = "$context" ]; then
+				if [ -n "$previous_context" ] && [ -n "$(entry "$into" contexts "$previous_context" context)" ]; then
+					KUBECONFIG="$into" kubectl config use-context "$previous_context" >/dev/null
+				else
+					KUBECONFIG="$into" kubectl config unset current-context >/dev/null
+				fi
+			fi
+			rm -f "$record"
+			echo "Took $context out of $into"
+		`)
+
+// withKubeconfigScript runs a snippet against the cluster.
+var withKubeconfigScript = script.New("with-kubeconfig", "export KUBECONFIG={{quote .KubeconfigPath}}\n{{.Script}}")
+
+var readKubeconfigScript = script.New("read-kubeconfig", `cat {{quote .KubeconfigPath}}`)
+
+// readClusterInfoScript prints the DOCKER_HOST of the host, resolved, and a
+// line per node with its name, kubelet version and internal IPs.
+var readClusterInfoScript = script.New("read-cluster-info", `
+			export DOCKER_HOST={{.DockerHost}}
+			echo "docker-host $DOCKER_HOST"
+			kubectl --kubeconfig {{quote .KubeconfigPath}} get nodes \
+				-o jsonpath='{range .items[*]}node {.metadata.name} {.status.nodeInfo.kubeletVersion} {.status.addresses[?(@.type=="InternalIP")].address}{"\n"}{end}'
+		`)
+
+// withDockerHostScript runs Script with DOCKER_HOST pointing at the host.
+var withDockerHostScript = script.New("with-docker-host", "export DOCKER_HOST={{.DockerHost}}\n{{.Script}}")
+
+var dockerHealthCheckScript = script.New("docker-health-check", `
+			export DOCKER_HOST={{.DockerHost}}
+			if ! docker ps >/dev/null 2>&1; then
+				echo "Docker is not reachable at $DOCKER_HOST"
+				exit 1
+			fi
+			echo "Docker is reachable at $DOCKER_HOST"
+		`)
+
+// kindNodesScript lists the nodes of the cluster, if it exists on a
+// reachable host.
+var kindNodesScript = script.New("kind-nodes", `
+			export DOCKER_HOST={{.DockerHost}}
+			kind get nodes --name {{quote .ClusterName}} 2>/dev/null || true
+		`)
+
+var kindHealthCheckScript = script.New("kind-health-check", `
+			export DOCKER_HOST={{.DockerHost}}
+			cluster_name={{quote .ClusterName}}
+			if ! kind get clusters 2>/dev/null | grep -qxF "$cluster_name"; then
+				echo "Kind cluster $cluster_name not found"
+				exit 1
+			fi
+			echo "Kind cluster $cluster_name exists"
+		`)
@@ -0,0 +1,263 @@
+package kindcluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"myk8s-cluster/addons"
+	"myk8s-cluster/autostart"
+	"myk8s-cluster/host"
+	"myk8s-cluster/kindconfig"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// ClusterSpec is the typed stack configuration for the host (usually a Lima
+// VM) and the Kind cluster running on it. It is loaded from the `cluster` config object,
+// e.g. `pulumi config set --path cluster.cpus 16`.
+type ClusterSpec struct {
+	// Host selects where Docker runs, see host.Names. "auto" uses a VM on
+	// macOS and the local Docker daemon on Linux.
+	Host string `json:"host"`
+	// VMBackend manages the VM of the vm host, see host.VMBackendNames.
+	VMBackend string `json:"vmBackend"`
+	// Autostart selects how the host and cluster are started after a
+	// reboot, see autostart.Names; "auto" picks launchd or systemd by OS.
+	Autostart string `json:"autostart"`
+	// Preflight is "fail" to fail before creating anything when a
+	// preflight check fails, "warn" to only log it, or "off".
+	Preflight string `json:"preflight"`
+	// HealthCheck is "fail" to fail the update when a critical health
+	// check fails, "warn" to only report it, or "off".
+	HealthCheck string `json:"healthCheck"`
+	// HealthReport writes the health report to files.
+	HealthReport HealthReportSpec `json:"healthReport"`
+	// Lima customises the VM of the lima backend.
+	Lima        LimaSpec `json:"lima"`
+	VMName      string   `json:"vmName"`
+	CPUs        int      `json:"cpus"`
+	Memory      int      `json:"memory"` // GB
+	Disk        int      `json:"disk"`   // GB
+	ClusterName string   `json:"clusterName"`
+	// KubeconfigPath is where the cluster kubeconfig is written, by default
+	// ~/.kube/<clusterName>-config. A leading ~/ is the home directory.
+	KubeconfigPath string `json:"kubeconfigPath"`
+	// MergeKubeconfig merges the cluster context into ~/.kube/config, and
+	// takes it out again on destroy.
+	MergeKubeconfig bool `json:"mergeKubeconfig"`
+	// ContextName is the name of the merged context, by default
+	// kind-<clusterName>.
+	ContextName string `json:"contextName"`
+	// CNI selects the pod network plugin, see cni.Names.
+	CNI           string `json:"cni"`
+	CalicoVersion string `json:"calicoVersion"`
+	// CalicoManifest is empty to download the manifest for CalicoVersion,
+	// or the path of a local copy.
+	CalicoManifest string `json:"calicoManifest"`
+	CiliumVersion  string `json:"ciliumVersion"`
+	FlannelVersion string `json:"flannelVersion"`
+	// Addons are the add-ons installed once the CNI is ready, keyed by
+	// name, see addons.Names. A listed add-on is installed unless its
+	// enabled setting is false.
+	Addons map[string]addons.Settings `json:"addons"`
+
+	// Node topology. ControlPlane and Worker apply to every node of that
+	// role; Nodes overrides individual nodes keyed by their kind name
+	// without the cluster prefix, e.g. "control-plane2" or "worker3".
+	ControlPlanes int                 `json:"controlPlanes"`
+	Workers       int                 `json:"workers"`
+	ControlPlane  NodeSpec            `json:"controlPlane"`
+	Worker        NodeSpec            `json:"worker"`
+	Nodes         map[string]NodeSpec `json:"nodes"`
+
+	// Kind cluster knobs, rendered into kind-config.yaml by kindConfig.
+	NodeImage               string                   `json:"nodeImage"`
+	Networking              kindconfig.Networking    `json:"networking"`
+	ExtraPortMappings       []kindconfig.PortMapping `json:"extraPortMappings"` // published by the first control-plane node
+	KubeadmConfigPatches    []string                 `json:"kubeadmConfigPatches"`
+	ContainerdConfigPatches []string                 `json:"containerdConfigPatches"`
+
+	// Clusters runs several kind clusters on the host. Each entry is laid
+	// over this spec, see ClusterSpecs, and may set anything but the host
+	// keys; this spec's own clusterName is then unused.
+	Clusters []json.RawMessage `json:"clusters"`
+}
+
+// DefaultClusterSpec returns the spec used when no configuration is set.
+func DefaultClusterSpec() ClusterSpec {
+	return ClusterSpec{
+		Host:            host.Auto,
+		VMBackend:       "lima",
+		Autostart:       autostart.Auto,
+		Preflight:       PreflightFail,
+		HealthCheck:     HealthCheckFail,
+		VMName:          "myk8s-docker",
+		CPUs:            8,
+		Memory:          16,
+		Disk:            500,
+		ClusterName:     "myk8s",
+		MergeKubeconfig: true,
+		CNI:             "calico",
+		CalicoVersion:   "v3.29.1",
+		CiliumVersion:   "1.16.5",
+		FlannelVersion:  "v0.26.2",
+		ControlPlanes:   1,
+		Workers:         3,
+		ControlPlane: NodeSpec{
+			Taints: []string{"node-role.kubernetes.io/control-plane:NoSchedule"},
+		},
+	}
+}
+
+// dnsLabel matches an RFC 1123 label, which is what Lima instance names,
+// Docker contexts and Kind node container names all have to satisfy.
+var dnsLabel = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+var calicoVersionPattern = regexp.MustCompile(`^v\d+\.\d+\.\d+$`)
+
+// LoadClusterSpec reads the `cluster` config object on top of the defaults,
+// applies the deprecated top-level keys and validates the result.
+func LoadClusterSpec(ctx *pulumi.Context) (ClusterSpec, error) {
+	conf := config.New(ctx, "")
+	spec := DefaultClusterSpec()
+	if err := conf.TryObject("cluster", &spec); err != nil && !errors.Is(err, config.ErrMissingVar) {
+		return spec, fmt.Errorf("invalid cluster config: %w", err)
+	}
+	// The keys set in the object, which win over the deprecated ones
+	var set map[string]json.RawMessage
+	if err := conf.TryObject("cluster", &set); err != nil && !errors.Is(err, config.ErrMissingVar) {
+		return spec, fmt.Errorf("invalid cluster config: %w", err)
+	}
+	if err := applyLegacyConfig(ctx, conf, &spec, set); err != nil {
+		return spec, fmt.Errorf("invalid cluster config:\n%w", err)
+	}
+
+	if err := spec.Validate(); err != nil {
+		return spec, fmt.Errorf("invalid cluster config:\n%w", err)
+	}
+	return spec, nil
+}
+
+// ParseClusterSpec reads a `cluster` config object, as JSON, on top of the
+// defaults and validates the result. An empty object is the defaults. Unlike
+// LoadClusterSpec it needs no Pulumi program, and it ignores the deprecated
+// top-level keys.
+func ParseClusterSpec(data string) (ClusterSpec, error) {
+	spec := DefaultClusterSpec()
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &spec); err != nil {
+			return spec, fmt.Errorf("invalid cluster config: %w", err)
+		}
+	}
+	if err := spec.Validate(); err != nil {
+		return spec, fmt.Errorf("invalid cluster config:\n%w", err)
+	}
+	return spec, nil
+}
+
+// applyLegacyConfig honors the flat keys (vmName, cpus, ...) that predate the
+// `cluster` object so existing stacks keep working. A flat key is ignored
+// when the same key is set in the object.
+func applyLegacyConfig(ctx *pulumi.Context, conf *config.Config, spec *ClusterSpec, set map[string]json.RawMessage) error {
+	var errs []error
+	stringKeys := map[string]*string{
+		"vmName":        &spec.VMName,
+		"clusterName":   &spec.ClusterName,
+		"calicoVersion": &spec.CalicoVersion,
+	}
+	for key, field := range stringKeys {
+		if v, err := conf.Try(key); err == nil {
+			if warnLegacyKey(ctx, key, set) {
+				*field = v
+			}
+		}
+	}
+	intKeys := map[string]*int{
+		"cpus":   &spec.CPUs,
+		"memory": &spec.Memory,
+		"disk":   &spec.Disk,
+	}
+	for key, field := range intKeys {
+		v, err := conf.TryInt(key)
+		switch {
+		case err == nil:
+			if warnLegacyKey(ctx, key, set) {
+				*field = v
+			}
+		case !errors.Is(err, config.ErrMissingVar):
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// warnLegacyKey warns that key is deprecated, and reports whether it applies
+// because cluster.<key> is not set.
+func warnLegacyKey(ctx *pulumi.Context, key string, set map[string]json.RawMessage) bool {
+	if _, ok := set[key]; ok {
+		_ = ctx.Log.Warn(fmt.Sprintf("config key %q is deprecated and ignored, cluster.%s is set", key, key), nil)
+		return false
+	}
+	_ = ctx.Log.Warn(fmt.Sprintf("config key %q is deprecated, use cluster.%s instead", key, key), nil)
+	return true
+}
+
+// Validate checks the spec and reports every problem at once.
+func (s ClusterSpec) Validate() error {
+	var errs []error
+	if !dnsLabel.MatchString(s.VMName) {
+		errs = append(errs, fmt.Errorf("vmName %q must be a DNS-safe name (lowercase letters, digits and '-')", s.VMName))
+	}
+	if !dnsLabel.MatchString(s.ClusterName) {
+		errs = append(errs, fmt.Errorf("clusterName %q must be a DNS-safe name (lowercase letters, digits and '-')", s.ClusterName))
+	}
+	if h, err := s.hostBackend(); err != nil {
+		errs = append(errs, err)
+	} else if c, ok := h.(host.ConfigFile); ok {
+		if _, _, err := c.ConfigFile(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if s.Autostart != autostart.Auto {
+		if _, err := autostart.New(s.Autostart, autostart.Options{}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	switch s.Preflight {
+	case PreflightFail, PreflightWarn, PreflightOff:
+	default:
+		errs = append(errs, fmt.Errorf("preflight %q must be %s, %s or %s", s.Preflight, PreflightFail, PreflightWarn, PreflightOff))
+	}
+	switch s.HealthCheck {
+	case HealthCheckFail, HealthCheckWarn, HealthCheckOff:
+	default:
+		errs = append(errs, fmt.Errorf("healthCheck %q must be %s, %s or %s", s.HealthCheck, HealthCheckFail, HealthCheckWarn, HealthCheckOff))
+	}
+	if err := s.Lima.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if s.CPUs <= 0 {
+		errs = append(errs, fmt.Errorf("cpus must be greater than 0, got %d", s.CPUs))
+	}
+	if s.Memory <= 0 {
+		errs = append(errs, fmt.Errorf("memory must be greater than 0, got %d", s.Memory))
+	}
+	if s.Disk <= 0 {
+		errs = append(errs, fmt.Errorf("disk must be greater than 0, got %d", s.Disk))
+	}
+	// Only the Calico plugins read calicoVersion
+	if (s.CNI == "calico" || s.CNI == "calico-operator") && !calicoVersionPattern.MatchString(s.CalicoVersion) {
+		errs = append(errs, fmt.Errorf("calicoVersion %q must look like v3.29.1", s.CalicoVersion))
+	}
+	errs = append(errs, s.validateNetwork()...)
+	errs = append(errs, s.validateAddons()...)
+	errs = append(errs, s.validateTopology()...)
+	errs = append(errs, s.validateClusters()...)
+	if err := s.kindConfig().Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("kind config: %w", err))
+	}
+	return errors.Join(errs...)
+}
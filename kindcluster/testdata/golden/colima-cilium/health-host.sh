@@ -0,0 +1,12 @@
+export DOCKER_HOST=unix://"$HOME"/.colima/myk8s-docker/docker.sock
+
+		vm_name=myk8s-docker
+		vm_backend=colima
+		vm_status() {
+			colima list 2>/dev/null | awk -v name=myk8s-docker 'NR > 1 && $1 == name { print $2; found=1 } END { if (!found) print "Missing" }'
+		}
+
+		status=$(vm_status)
+		echo "$vm_backend VM $vm_name is $status"
+		[ "$status" = "Running" ]
+	
\ No newline at end of file
@@ -0,0 +1,41 @@
+export DOCKER_HOST=unix://"$HOME"/.colima/myk8s-docker/docker.sock
+
+		vm_name=myk8s-docker
+		vm_backend=colima
+		vm_status() {
+			colima list 2>/dev/null | awk -v name=myk8s-docker 'NR > 1 && $1 == name { print $2; found=1 } END { if (!found) print "Missing" }'
+		}
+
+		# Create or start the VM depending on its current state
+		case "$(vm_status)" in
+			Running)
+				echo "VM $vm_name is already running"
+				;;
+			Missing)
+				echo "Creating new $vm_backend VM $vm_name..."
+				colima start --profile myk8s-docker --runtime docker --cpu 8 --memory 2 --disk 500 --vm-type qemu
+				;;
+			*)
+				echo "VM $vm_name exists but not running, starting..."
+				colima start --profile myk8s-docker
+				;;
+		esac
+
+		# Wait for VM to be fully ready with retry logic
+		max_attempts=30
+		attempt=0
+		while [ $attempt -lt $max_attempts ]; do
+			if [ "$(vm_status)" = "Running" ]; then
+				echo "VM $vm_name is ready"
+				break
+			fi
+			echo "Waiting for VM to be ready... (attempt $((attempt+1))/$max_attempts)"
+			sleep 2
+			attempt=$((attempt+1))
+		done
+
+		if [ $attempt -eq $max_attempts ]; then
+			echo "ERROR: VM failed to start after $max_attempts attempts"
+			exit 1
+		fi
+	
\ No newline at end of file
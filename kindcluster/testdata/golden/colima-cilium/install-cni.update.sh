@@ -0,0 +1,5 @@
+export KUBECONFIG=/home/dev/.kube/myk8s-config
+
+		echo "Removing the Cilium release installed by the helm CLI..."
+		helm uninstall cilium --namespace kube-system --wait 2>/dev/null || true
+	
\ No newline at end of file
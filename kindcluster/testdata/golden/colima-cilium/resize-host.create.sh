@@ -0,0 +1 @@
+echo 'cpus=8 memory=2 disk=500'
\ No newline at end of file
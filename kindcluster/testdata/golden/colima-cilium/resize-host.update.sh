@@ -0,0 +1,52 @@
+
+			# The previous size is the last line of the previous run
+			previous_disk=$(printf '%s\n' "$PULUMI_COMMAND_STDOUT" | sed -n 's/.*disk=\([0-9]*\).*/\1/p' | tail -n 1)
+			if [ -n "$previous_disk" ] && [ 500 -lt "$previous_disk" ]; then
+				echo "ERROR: disk cannot shrink from ${previous_disk}GB to 500GB, destroy and recreate the host to use a smaller disk" >&2
+				exit 1
+			fi
+			
+		vm_name=myk8s-docker
+		vm_backend=colima
+		vm_status() {
+			colima list 2>/dev/null | awk -v name=myk8s-docker 'NR > 1 && $1 == name { print $2; found=1 } END { if (!found) print "Missing" }'
+		}
+
+		echo "Stopping $vm_backend VM $vm_name to resize it..."
+		colima stop --profile myk8s-docker 2>/dev/null || true
+		max_attempts=30
+		attempt=0
+		while [ "$(vm_status)" = "Running" ] && [ $attempt -lt $max_attempts ]; do
+			sleep 2
+			attempt=$((attempt+1))
+		done
+
+		echo "Resizing $vm_backend VM $vm_name..."
+		colima start --profile myk8s-docker --cpu 8 --memory 2 --disk 500
+
+		echo "Starting $vm_backend VM $vm_name..."
+		if [ "$(vm_status)" != "Running" ]; then
+			colima start --profile myk8s-docker
+		fi
+		attempt=0
+		while [ "$(vm_status)" != "Running" ]; do
+			if [ $attempt -ge $max_attempts ]; then
+				echo "ERROR: VM failed to start after resizing"
+				exit 1
+			fi
+			sleep 2
+			attempt=$((attempt+1))
+		done
+	
+
+			# Bring the kind nodes back and wait for them before the health checks
+			export DOCKER_HOST=unix://"$HOME"/.colima/myk8s-docker/docker.sock
+			for cluster_name in myk8s; do
+				if kind get clusters 2>/dev/null | grep -qxF "$cluster_name"; then
+					docker start $(docker ps -aq --filter label=io.x-k8s.kind.cluster="$cluster_name") >/dev/null
+					docker exec "$cluster_name-control-plane" kubectl --kubeconfig /etc/kubernetes/admin.conf \
+						wait --for=condition=Ready nodes --all --timeout=300s
+				fi
+			done
+			echo 'cpus=8 memory=2 disk=500'
+		
\ No newline at end of file
@@ -0,0 +1,11 @@
+export KUBECONFIG=/home/dev/.kube/myk8s-config
+
+		workload=ds/cilium
+		echo "Waiting for $workload in "kube-system" to be ready..."
+		if kubectl -n kube-system rollout status "$workload" --timeout=120s; then
+			echo "$workload is ready!"
+		else
+			echo "Warning: Timed out waiting for $workload to be ready"
+			kubectl -n kube-system get pods -l k8s-app=cilium
+		fi
+	
\ No newline at end of file
@@ -0,0 +1,35 @@
+printf '%s' 'kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+networking:
+  disableDefaultCNI: true
+nodes:
+  - role: control-plane
+    labels:
+      ingress-ready: "true"
+    extraMounts:
+      - hostPath: /tmp/myk8s-control-disk
+        containerPath: /var/lib/disk1
+    extraPortMappings:
+      - containerPort: 80
+        hostPort: 8080
+        protocol: TCP
+      - containerPort: 443
+        hostPort: 443
+        protocol: TCP
+  - role: worker
+    extraMounts:
+      - hostPath: /tmp/myk8s-worker1-disk
+        containerPath: /var/lib/disk1
+  - role: worker
+    extraMounts:
+      - hostPath: /tmp/myk8s-worker2-disk
+        containerPath: /var/lib/disk1
+  - role: worker
+    extraMounts:
+      - hostPath: /tmp/myk8s-worker3-disk
+        containerPath: /var/lib/disk1
+containerdConfigPatches:
+  - |
+    [plugins."io.containerd.grpc.v1.cri".registry]
+      config_path = "/etc/containerd/certs.d"
+' > ./kind-config.yaml
\ No newline at end of file
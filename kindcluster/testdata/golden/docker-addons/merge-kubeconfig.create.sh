@@ -0,0 +1 @@
+echo "Context "kind-myk8s" is merged into "/home/dev/.kube/config
\ No newline at end of file
@@ -0,0 +1 @@
+cat /home/dev/.kube/myk8s-config
\ No newline at end of file
@@ -0,0 +1,21 @@
+
+		export DOCKER_HOST=unix:///var/run/docker.sock
+		name=myk8s-registry
+		if [ "$(docker inspect -f '{{.State.Running}}' "$name" 2>/dev/null)" != true ]; then
+			echo "Starting the registry $name on localhost:5001..."
+			docker rm -f "$name" >/dev/null 2>&1 || true
+			docker run -d --restart=always --name "$name" \
+				-p 127.0.0.1:5001:5000 \
+				-v "$name:/var/lib/registry" \
+				registry:2
+		fi
+		# Nodes reach the registry by its container name on the kind network
+		if [ "$(docker inspect -f '{{json .NetworkSettings.Networks.kind}}' "$name")" = null ]; then
+			docker network connect kind "$name"
+		fi
+		# and containerd on each node finds it for localhost:5001
+		for node in $(kind get nodes --name myk8s); do
+			docker exec "$node" mkdir -p /etc/containerd/certs.d/localhost:5001
+			printf '%s\n' '[host."http://myk8s-registry:5000"]' | docker exec -i "$node" cp /dev/stdin /etc/containerd/certs.d/localhost:5001/hosts.toml
+		done
+	
\ No newline at end of file
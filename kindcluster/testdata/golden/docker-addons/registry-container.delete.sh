@@ -0,0 +1,6 @@
+
+		export DOCKER_HOST=unix:///var/run/docker.sock
+		echo "Removing the registry "myk8s-registry" and its images..."
+		docker rm -f myk8s-registry 2>/dev/null || true
+		docker volume rm myk8s-registry 2>/dev/null || true
+	
\ No newline at end of file
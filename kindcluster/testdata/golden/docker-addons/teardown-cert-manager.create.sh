@@ -0,0 +1 @@
+echo "Nothing to do until the add-on is removed"
\ No newline at end of file
@@ -0,0 +1,7 @@
+export KUBECONFIG=/home/dev/.kube/myk8s-config
+
+		echo "Removing what cert-manager left behind..."
+		kubectl delete namespace cert-manager --ignore-not-found --request-timeout=30s 2>/dev/null || true
+		kubectl -n kube-system delete lease cert-manager-controller cert-manager-cainjector-leader-election \
+			--ignore-not-found --request-timeout=30s 2>/dev/null || true
+	
\ No newline at end of file
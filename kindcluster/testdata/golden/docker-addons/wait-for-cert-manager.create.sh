@@ -0,0 +1,12 @@
+export KUBECONFIG=/home/dev/.kube/myk8s-config
+
+		for workload in deploy/cert-manager deploy/cert-manager-cainjector deploy/cert-manager-webhook; do
+			echo "Waiting for $workload in "cert-manager" to be ready..."
+			if kubectl -n cert-manager rollout status "$workload" --timeout=120s; then
+				echo "$workload is ready!"
+			else
+				echo "Warning: Timed out waiting for $workload to be ready"
+				kubectl -n cert-manager get pods -l app.kubernetes.io/instance=cert-manager
+			fi
+		done
+	
\ No newline at end of file
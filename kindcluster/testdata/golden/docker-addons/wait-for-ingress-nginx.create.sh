@@ -0,0 +1,12 @@
+export KUBECONFIG=/home/dev/.kube/myk8s-config
+
+		for workload in deploy/ingress-nginx-controller; do
+			echo "Waiting for $workload in "ingress-nginx" to be ready..."
+			if kubectl -n ingress-nginx rollout status "$workload" --timeout=120s; then
+				echo "$workload is ready!"
+			else
+				echo "Warning: Timed out waiting for $workload to be ready"
+				kubectl -n ingress-nginx get pods -l app.kubernetes.io/component=controller
+			fi
+		done
+	
\ No newline at end of file
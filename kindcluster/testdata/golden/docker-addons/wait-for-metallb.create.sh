@@ -0,0 +1,12 @@
+export KUBECONFIG=/home/dev/.kube/myk8s-config
+
+		for workload in deploy/controller ds/speaker; do
+			echo "Waiting for $workload in "metallb-system" to be ready..."
+			if kubectl -n metallb-system rollout status "$workload" --timeout=120s; then
+				echo "$workload is ready!"
+			else
+				echo "Warning: Timed out waiting for $workload to be ready"
+				kubectl -n metallb-system get pods -l app=metallb
+			fi
+		done
+	
\ No newline at end of file
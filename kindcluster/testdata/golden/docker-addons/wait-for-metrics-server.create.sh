@@ -0,0 +1,12 @@
+export KUBECONFIG=/home/dev/.kube/myk8s-config
+
+		for workload in deploy/metrics-server; do
+			echo "Waiting for $workload in "kube-system" to be ready..."
+			if kubectl -n kube-system rollout status "$workload" --timeout=120s; then
+				echo "$workload is ready!"
+			else
+				echo "Warning: Timed out waiting for $workload to be ready"
+				kubectl -n kube-system get pods -l app.kubernetes.io/name=metrics-server
+			fi
+		done
+	
\ No newline at end of file
@@ -0,0 +1,16 @@
+export KUBECONFIG=/home/dev/.kube/myk8s-config
+
+		export DOCKER_HOST=unix:///var/run/docker.sock
+		url=http://myk8s-registry:5000/v2/
+		echo "Waiting for the registry to answer at $url..."
+		attempt=0
+		until docker exec myk8s-control-plane curl -sf -o /dev/null "$url"; do
+			attempt=$((attempt+1))
+			if [ $attempt -ge 20 ]; then
+				echo "Warning: Timed out waiting for the registry"
+				exit 0
+			fi
+			sleep 3
+		done
+		echo "The registry is ready!"
+	
\ No newline at end of file
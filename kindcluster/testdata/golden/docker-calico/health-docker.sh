@@ -0,0 +1,8 @@
+
+			export DOCKER_HOST=unix:///var/run/docker.sock
+			if ! docker ps >/dev/null 2>&1; then
+				echo "Docker is not reachable at $DOCKER_HOST"
+				exit 1
+			fi
+			echo "Docker is reachable at $DOCKER_HOST"
+		
\ No newline at end of file
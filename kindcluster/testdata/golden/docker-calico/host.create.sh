@@ -0,0 +1,10 @@
+export DOCKER_HOST=unix:///var/run/docker.sock
+
+		echo "Using local Docker daemon at $DOCKER_HOST"
+		if ! docker info >/dev/null 2>&1; then
+			echo "ERROR: Docker is not reachable at $DOCKER_HOST"
+			echo "Start it (or rootless Docker) and make sure your user can access the socket"
+			exit 1
+		fi
+		echo "Docker $(docker version --format '{{.Server.Version}}') is ready"
+	
\ No newline at end of file
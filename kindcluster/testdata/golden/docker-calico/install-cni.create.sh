@@ -0,0 +1 @@
+echo "The CNI is installed as Kubernetes resources"
\ No newline at end of file
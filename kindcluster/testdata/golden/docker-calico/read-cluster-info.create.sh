@@ -0,0 +1,6 @@
+
+			export DOCKER_HOST=unix:///var/run/docker.sock
+			echo "docker-host $DOCKER_HOST"
+			kubectl --kubeconfig /home/dev/.kube/myk8s-config get nodes \
+				-o jsonpath='{range .items[*]}node {.metadata.name} {.status.nodeInfo.kubeletVersion} {.status.addresses[?(@.type=="InternalIP")].address}{"\n"}{end}'
+		
\ No newline at end of file
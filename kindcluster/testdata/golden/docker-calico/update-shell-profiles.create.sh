@@ -0,0 +1,19 @@
+
+		echo "Updating shell profiles..."
+		for profile in  "$HOME"/.zshrc "$HOME"/.bashrc; do
+			for line in 'export KUBECONFIG=/home/dev/.kube/myk8s-config'; do
+				if ! grep -qxF -- "$line" "$profile" 2>/dev/null; then
+					echo "$line" >> "$profile"
+					echo "Updated $profile with ${line%%=*}"
+				fi
+			done
+		done
+
+		mkdir -p ~/bin
+		printf '%s\n' '#!/bin/bash
+export KUBECONFIG=/home/dev/.kube/myk8s-config
+echo Kubernetes context set to myk8s
+kubectl cluster-info' > ~/bin/use-k8s.sh
+		chmod +x ~/bin/use-k8s.sh
+		echo "Created activation script at ~/bin/use-k8s.sh"
+	
\ No newline at end of file
@@ -0,0 +1,59 @@
+
+			if ! systemctl --user show-environment >/dev/null 2>&1; then
+				echo "WARNING: no systemd user manager, skipping autostart"
+				exit 0
+			fi
+	
+			for bin in limactl docker; do
+				if ! command -v "$bin" >/dev/null 2>&1; then
+					echo "ERROR: $bin not found in PATH"
+					exit 1
+				fi
+			done
+			mkdir -p /home/dev/.local/share/myk8s-cluster
+			printf "#!/bin/sh\nexport PATH='%s'\n" "$PATH" > /home/dev/.local/share/myk8s-cluster/myk8s-cluster.myk8s.sh
+			printf '%s\n' '
+		vm_name=myk8s-docker
+		vm_backend=lima
+		vm_status() {
+			limactl list --format '\''{{.Name}} {{.Status}}'\'' 2>/dev/null | awk -v name=myk8s-docker '\''$1 == name { print $2; found=1 } END { if (!found) print "Missing" }'\''
+		}
+
+		if [ "$(vm_status)" != "Running" ]; then
+			limactl start --tty=false myk8s-docker
+		fi
+	
+		export DOCKER_HOST=unix://"$HOME"/.lima/myk8s-docker/sock/docker.sock
+		attempt=0
+		until docker info >/dev/null 2>&1; do
+			if [ $attempt -ge 60 ]; then
+				echo "ERROR: Docker is not reachable at $DOCKER_HOST"
+				exit 1
+			fi
+			sleep 2
+			attempt=$((attempt+1))
+		done
+		for cluster_name in myk8s; do
+			nodes=$(docker ps -aq --filter label=io.x-k8s.kind.cluster="$cluster_name")
+			if [ -n "$nodes" ]; then
+				docker start $nodes
+			fi
+		done
+	' >> /home/dev/.local/share/myk8s-cluster/myk8s-cluster.myk8s.sh
+			chmod +x /home/dev/.local/share/myk8s-cluster/myk8s-cluster.myk8s.sh
+	
+			unit_path=/home/dev/.config/systemd/user/myk8s-cluster.myk8s.service
+			mkdir -p "$(dirname "$unit_path")"
+			printf '%s\n' '[Unit]
+Description=Start myk8s-cluster.myk8s
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+ExecStart=/bin/sh "/home/dev/.local/share/myk8s-cluster/myk8s-cluster.myk8s.sh"
+
+[Install]
+WantedBy=default.target' > "$unit_path"
+			systemctl --user daemon-reload
+			systemctl --user enable myk8s-cluster.myk8s.service
+	
\ No newline at end of file
@@ -0,0 +1,9 @@
+
+			export DOCKER_HOST=unix://"$HOME"/.lima/myk8s-docker/sock/docker.sock
+			cluster_name=myk8s
+			if ! kind get clusters 2>/dev/null | grep -qxF "$cluster_name"; then
+				echo "Kind cluster $cluster_name not found"
+				exit 1
+			fi
+			echo "Kind cluster $cluster_name exists"
+		
\ No newline at end of file
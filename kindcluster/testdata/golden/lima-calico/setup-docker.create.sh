@@ -0,0 +1,7 @@
+
+				docker_context=lima-myk8s-docker
+				docker context rm "$docker_context" 2>/dev/null || true
+				docker context create "$docker_context" --docker host=unix://"$HOME"/.lima/myk8s-docker/sock/docker.sock || true
+				docker context use "$docker_context" || true
+				echo "Current Docker context: $(docker context show)"
+			
\ No newline at end of file
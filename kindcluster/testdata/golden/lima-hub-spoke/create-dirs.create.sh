@@ -0,0 +1 @@
+mkdir -p /tmp/hub-control-disk /tmp/hub-worker1-disk
\ No newline at end of file
@@ -0,0 +1,14 @@
+
+			export DOCKER_HOST=unix://"$HOME"/.lima/myk8s-docker/sock/docker.sock
+			cluster_name=hub
+
+			echo "Deleting Kind cluster '$cluster_name'..."
+			# Delete the Kind cluster
+			if kind get clusters 2>/dev/null | grep -qxF "$cluster_name"; then
+				kind delete cluster --name "$cluster_name"
+				echo "Kind cluster '$cluster_name' deleted successfully"
+			else
+				echo "Kind cluster '$cluster_name' not found, skipping deletion"
+			fi
+			rm -f /home/dev/.local/share/myk8s-cluster/kind/hub.yaml
+		
\ No newline at end of file
@@ -0,0 +1,14 @@
+printf '%s' 'kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+networking:
+  disableDefaultCNI: true
+nodes:
+  - role: control-plane
+    extraMounts:
+      - hostPath: /tmp/hub-control-disk
+        containerPath: /var/lib/disk1
+  - role: worker
+    extraMounts:
+      - hostPath: /tmp/hub-worker1-disk
+        containerPath: /var/lib/disk1
+' > ./kind-config.yaml
\ No newline at end of file
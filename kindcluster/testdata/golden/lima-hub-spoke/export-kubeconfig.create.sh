@@ -0,0 +1,29 @@
+
+			cluster_name=hub
+			kubeconfig=/home/dev/.kube/hub-config
+
+			# Create .kube directory if it doesn't exist
+			mkdir -p /home/dev/.kube
+
+			# Export kubeconfig to a specific file
+			echo "Exporting kubeconfig to $kubeconfig"
+			DOCKER_HOST=unix://"$HOME"/.lima/myk8s-docker/sock/docker.sock kind export kubeconfig --name "$cluster_name" --kubeconfig "$kubeconfig"
+
+			# Make sure the kubeconfig file is accessible
+			chmod 600 "$kubeconfig"
+
+			# Export the KUBECONFIG environment variable for this session
+			export KUBECONFIG="$kubeconfig"
+
+			# Fix the kubeconfig if it has localhost references (often causes connection issues)
+			# Replace localhost with 127.0.0.1 which is more reliable
+			sed -i.bak 's|server: https://localhost:|server: https://127.0.0.1:|g' "$kubeconfig"
+
+			# Automatically set kubectl context to the new cluster
+			kubectl config use-context "kind-$cluster_name"
+
+			# Verify the kubeconfig is valid
+			echo "Testing kubectl configuration..."
+			kubectl version --client || true
+			echo "Current kubectl context: $(kubectl config current-context)"
+		
\ No newline at end of file
@@ -0,0 +1,13 @@
+
+			kubeconfig=/home/dev/.kube/hub-config
+			default_kubeconfig=/home/dev/.kube/config
+
+			# Remove the kubeconfig file during cleanup
+			rm -f "$kubeconfig" 2>/dev/null || true
+			rm -f "$kubeconfig.bak" 2>/dev/null || true
+
+			# Remove the symlink earlier versions made, if it points to our config
+			if [ -L "$default_kubeconfig" ] && [ "$(readlink "$default_kubeconfig")" = "$kubeconfig" ]; then
+				rm -f "$default_kubeconfig" 2>/dev/null || true
+			fi
+		
\ No newline at end of file
@@ -0,0 +1,12 @@
+export DOCKER_HOST=unix://"$HOME"/.lima/myk8s-docker/sock/docker.sock
+
+		vm_name=myk8s-docker
+		vm_backend=lima
+		vm_status() {
+			limactl list --format '{{.Name}} {{.Status}}' 2>/dev/null | awk -v name=myk8s-docker '$1 == name { print $2; found=1 } END { if (!found) print "Missing" }'
+		}
+
+		status=$(vm_status)
+		echo "$vm_backend VM $vm_name is $status"
+		[ "$status" = "Running" ]
+	
\ No newline at end of file
@@ -0,0 +1 @@
+echo "Context "kind-hub" is merged into "/home/dev/.kube/config
\ No newline at end of file
@@ -0,0 +1 @@
+cat /home/dev/.kube/hub-config
\ No newline at end of file
@@ -0,0 +1 @@
+mkdir -p /tmp/spoke-control-disk
\ No newline at end of file
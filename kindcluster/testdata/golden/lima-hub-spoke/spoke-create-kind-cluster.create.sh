@@ -0,0 +1,23 @@
+
+			export DOCKER_HOST=unix://"$HOME"/.lima/myk8s-docker/sock/docker.sock
+			cluster_name=spoke
+
+			# Check if cluster already exists
+			if kind get clusters | grep -qxF "$cluster_name"; then
+				echo "Kind cluster '$cluster_name' already exists"
+			else
+				echo "Creating Kind cluster '$cluster_name'..."
+				kind create cluster --name "$cluster_name" --config ./kind-config-spoke.yaml
+				# Kind only reads its config now; keep it to tell later changes apart
+				mkdir -p "$(dirname /home/dev/.local/share/myk8s-cluster/kind/spoke.yaml)"
+				cp ./kind-config-spoke.yaml /home/dev/.local/share/myk8s-cluster/kind/spoke.yaml
+			fi
+
+			# Verify cluster is accessible
+			if kind get clusters | grep -qxF "$cluster_name"; then
+				echo "Kind cluster '$cluster_name' verified successfully"
+			else
+				echo "ERROR: Failed to create or verify Kind cluster"
+				exit 1
+			fi
+		
\ No newline at end of file
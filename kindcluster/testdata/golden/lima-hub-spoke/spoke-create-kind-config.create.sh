@@ -0,0 +1,11 @@
+printf '%s' 'kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+networking:
+  podSubnet: 10.245.0.0/16
+  serviceSubnet: 10.97.0.0/16
+nodes:
+  - role: control-plane
+    extraMounts:
+      - hostPath: /tmp/spoke-control-disk
+        containerPath: /var/lib/disk1
+' > ./kind-config-spoke.yaml
\ No newline at end of file
@@ -0,0 +1 @@
+rm -f ./kind-config-spoke.yaml
\ No newline at end of file
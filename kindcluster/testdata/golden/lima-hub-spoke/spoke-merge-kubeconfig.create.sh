@@ -0,0 +1 @@
+echo "Context "kind-spoke" is merged into "/home/dev/.kube/config
\ No newline at end of file
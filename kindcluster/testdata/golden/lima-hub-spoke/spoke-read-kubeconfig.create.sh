@@ -0,0 +1 @@
+cat /home/dev/.kube/spoke.yaml
\ No newline at end of file
@@ -0,0 +1,13 @@
+
+		for profile in  "$HOME"/.zshrc "$HOME"/.bashrc; do
+			[ -f "$profile" ] || continue
+			for line in 'export KUBECONFIG=/home/dev/.kube/hub-config' 'export DOCKER_CONTEXT=lima-myk8s-docker'; do
+				grep -vxF -- "$line" "$profile" > "$profile.tmp" || true
+				cat "$profile.tmp" > "$profile"
+				rm -f "$profile.tmp"
+			done
+		done
+
+		# Remove activation script
+		rm -f ~/bin/use-k8s.sh 2>/dev/null || true
+	
\ No newline at end of file
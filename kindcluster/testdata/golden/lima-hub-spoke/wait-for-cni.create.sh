@@ -0,0 +1,33 @@
+export KUBECONFIG=/home/dev/.kube/hub-config
+
+		echo "Waiting for Calico pods to be ready..."
+
+		timeout=120
+		interval=3
+		elapsed=0
+		while [ $elapsed -lt $timeout ]; do
+			# Use kubectl wait for efficiency
+			if kubectl wait --for=condition=ready pods -l k8s-app=calico-node -n kube-system --timeout=3s 2>/dev/null; then
+				echo "All Calico pods are ready!"
+				break
+			fi
+
+			# Fallback to manual checking if kubectl wait fails
+			ready_pods=$(kubectl -n kube-system get pods -l k8s-app=calico-node -o jsonpath='{.items[*].status.containerStatuses[*].ready}' | tr ' ' '\n' | grep -c "true" || echo "0")
+			desired_pods=$(kubectl -n kube-system get pods -l k8s-app=calico-node --no-headers | wc -l | tr -d ' ')
+
+			if [ "$ready_pods" -eq "$desired_pods" ] && [ "$desired_pods" -ge 1 ]; then
+				echo "All Calico pods are ready ($ready_pods/$desired_pods)."
+				break
+			fi
+
+			echo "Waiting for Calico pods... ($ready_pods/$desired_pods ready)"
+			sleep $interval
+			elapsed=$((elapsed + interval))
+		done
+
+		if [ $elapsed -ge $timeout ]; then
+			echo "Warning: Timed out waiting for Calico pods to be ready"
+			kubectl -n kube-system get pods -l k8s-app=calico-node
+		fi
+	
\ No newline at end of file
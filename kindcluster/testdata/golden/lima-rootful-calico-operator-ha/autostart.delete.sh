@@ -0,0 +1,5 @@
+
+			# Unload and remove the launchd agent
+			launchctl unload /home/dev/Library/LaunchAgents/myk8s-cluster.myk8s.plist 2>/dev/null || true
+			rm -f /home/dev/Library/LaunchAgents/myk8s-cluster.myk8s.plist /home/dev/.local/share/myk8s-cluster/myk8s-cluster.myk8s.sh 2>/dev/null || true
+	
\ No newline at end of file
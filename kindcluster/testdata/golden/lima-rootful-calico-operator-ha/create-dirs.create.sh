@@ -0,0 +1 @@
+mkdir -p /tmp/myk8s-control-disk /tmp/myk8s-control2-disk /tmp/myk8s-control3-disk /tmp/myk8s-worker1-disk /tmp/myk8s-worker2-disk
\ No newline at end of file
@@ -0,0 +1,31 @@
+printf '%s' 'kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+networking:
+  disableDefaultCNI: true
+nodes:
+  - role: control-plane
+    extraMounts:
+      - hostPath: /tmp/myk8s-control-disk
+        containerPath: /var/lib/disk1
+    extraPortMappings:
+      - containerPort: 80
+        hostPort: 8080
+  - role: control-plane
+    extraMounts:
+      - hostPath: /tmp/myk8s-control2-disk
+        containerPath: /var/lib/disk1
+  - role: control-plane
+    extraMounts:
+      - hostPath: /tmp/myk8s-control3-disk
+        containerPath: /var/lib/disk1
+  - role: worker
+    extraMounts:
+      - hostPath: /tmp/myk8s-worker1-disk
+        containerPath: /var/lib/disk1
+  - role: worker
+    labels:
+      tier: storage
+    extraMounts:
+      - hostPath: /tmp/myk8s-worker2-disk
+        containerPath: /var/lib/disk1
+' > ./kind-config.yaml
\ No newline at end of file
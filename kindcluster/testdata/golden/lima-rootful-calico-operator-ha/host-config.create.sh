@@ -0,0 +1,49 @@
+printf '%s' 'vmType: vz
+images:
+  - location: https://cloud-images.ubuntu.com/releases/noble/release/ubuntu-24.04-server-cloudimg-amd64.img
+    arch: x86_64
+  - location: https://cloud-images.ubuntu.com/releases/noble/release/ubuntu-24.04-server-cloudimg-arm64.img
+    arch: aarch64
+cpus: 8
+memory: 2GiB
+disk: 500GiB
+mounts:
+  - location: "~"
+  - location: /tmp/lima
+    writable: true
+containerd:
+  system: false
+  user: false
+provision:
+  - mode: system
+    script: |
+      #!/bin/bash
+      set -eux -o pipefail
+      command -v docker >/dev/null 2>&1 && exit 0
+      export DEBIAN_FRONTEND=noninteractive
+      curl -fsSL https://get.docker.com | sh
+      # let the Lima user forward the socket without sudo
+      mkdir -p /etc/systemd/system/docker.socket.d
+      printf '\''[Socket]\nSocketUser={{.User}}\n'\'' > /etc/systemd/system/docker.socket.d/override.conf
+      systemctl daemon-reload
+      systemctl restart docker.socket docker
+probes:
+  - script: |
+      #!/bin/bash
+      set -eux -o pipefail
+      if ! timeout 30s bash -c "until command -v docker >/dev/null 2>&1; do sleep 3; done"; then
+        echo >&2 "docker is not installed yet"
+        exit 1
+      fi
+      if ! timeout 30s bash -c "until pgrep dockerd; do sleep 3; done"; then
+        echo >&2 "dockerd is not running"
+        exit 1
+      fi
+    hint: See "/var/log/cloud-init-output.log" in the guest
+portForwards:
+  - guestSocket: /var/run/docker.sock
+    hostSocket: '\''{{.Dir}}/sock/docker.sock'\''
+hostResolver:
+  hosts:
+    host.docker.internal: host.lima.internal
+' > ./lima-myk8s-docker.yaml
\ No newline at end of file
@@ -0,0 +1 @@
+rm -f ./lima-myk8s-docker.yaml
\ No newline at end of file
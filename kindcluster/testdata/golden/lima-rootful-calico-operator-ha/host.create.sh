@@ -0,0 +1,42 @@
+export DOCKER_HOST=unix://"$HOME"/.lima/myk8s-docker/sock/docker.sock
+
+		vm_name=myk8s-docker
+		vm_backend=lima
+		vm_status() {
+			limactl list --format '{{.Name}} {{.Status}}' 2>/dev/null | awk -v name=myk8s-docker '$1 == name { print $2; found=1 } END { if (!found) print "Missing" }'
+		}
+
+		# Create or start the VM depending on its current state
+		case "$(vm_status)" in
+			Running)
+				echo "VM $vm_name is already running"
+				;;
+			Missing)
+				echo "Creating new $vm_backend VM $vm_name..."
+				limactl create --tty=false --name myk8s-docker ./lima-myk8s-docker.yaml
+				limactl start --tty=false myk8s-docker
+				;;
+			*)
+				echo "VM $vm_name exists but not running, starting..."
+				limactl start --tty=false myk8s-docker
+				;;
+		esac
+
+		# Wait for VM to be fully ready with retry logic
+		max_attempts=30
+		attempt=0
+		while [ $attempt -lt $max_attempts ]; do
+			if [ "$(vm_status)" = "Running" ]; then
+				echo "VM $vm_name is ready"
+				break
+			fi
+			echo "Waiting for VM to be ready... (attempt $((attempt+1))/$max_attempts)"
+			sleep 2
+			attempt=$((attempt+1))
+		done
+
+		if [ $attempt -eq $max_attempts ]; then
+			echo "ERROR: VM failed to start after $max_attempts attempts"
+			exit 1
+		fi
+	
\ No newline at end of file
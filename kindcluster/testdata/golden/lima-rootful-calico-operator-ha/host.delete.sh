@@ -0,0 +1,37 @@
+
+			# First, try to delete the Kind clusters that might be running on this host
+			for cluster_name in myk8s; do
+				DOCKER_HOST=unix://"$HOME"/.lima/myk8s-docker/sock/docker.sock kind delete cluster --name "$cluster_name" 2>/dev/null || true
+			done
+			
+		vm_name=myk8s-docker
+		vm_backend=lima
+		vm_status() {
+			limactl list --format '{{.Name}} {{.Status}}' 2>/dev/null | awk -v name=myk8s-docker '$1 == name { print $2; found=1 } END { if (!found) print "Missing" }'
+		}
+
+		# Stop the VM first (required before deletion)
+		echo "Stopping $vm_backend VM $vm_name..."
+		limactl stop myk8s-docker 2>/dev/null || true
+
+		# Wait for VM to stop
+		max_attempts=30
+		attempt=0
+		while [ $attempt -lt $max_attempts ]; do
+			if [ "$(vm_status)" != "Running" ]; then
+				echo "VM $vm_name stopped successfully"
+				break
+			fi
+			echo "Waiting for VM to stop... (attempt $((attempt+1))/$max_attempts)"
+			sleep 2
+			attempt=$((attempt+1))
+		done
+
+		echo "Deleting $vm_backend VM $vm_name..."
+		limactl delete --force myk8s-docker 2>/dev/null || true
+		# Clean up any leftover sockets and temp files
+		rm -rf "$HOME"/.lima/myk8s-docker/sock/* 2>/dev/null || true
+
+		echo "$vm_backend VM $vm_name cleanup completed"
+	
+		
\ No newline at end of file
@@ -0,0 +1,22 @@
+
+		echo "Updating shell profiles..."
+		for profile in  "$HOME"/.zshrc "$HOME"/.bashrc; do
+			for line in 'export KUBECONFIG=/home/dev/.kube/myk8s-config' 'export DOCKER_CONTEXT=lima-myk8s-docker'; do
+				if ! grep -qxF -- "$line" "$profile" 2>/dev/null; then
+					echo "$line" >> "$profile"
+					echo "Updated $profile with ${line%%=*}"
+				fi
+			done
+		done
+
+		mkdir -p ~/bin
+		printf '%s\n' '#!/bin/bash
+export KUBECONFIG=/home/dev/.kube/myk8s-config
+export DOCKER_CONTEXT=lima-myk8s-docker
+echo Kubernetes context set to myk8s
+echo Docker context set to lima-myk8s-docker
+kubectl cluster-info
+docker context show' > ~/bin/use-k8s.sh
+		chmod +x ~/bin/use-k8s.sh
+		echo "Created activation script at ~/bin/use-k8s.sh"
+	
\ No newline at end of file
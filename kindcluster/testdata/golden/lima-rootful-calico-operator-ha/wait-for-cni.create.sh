@@ -0,0 +1,15 @@
+export KUBECONFIG=/home/dev/.kube/myk8s-config
+
+		echo "Waiting for Calico to be reported available by the operator..."
+		attempt=0
+		until kubectl get tigerastatus/calico >/dev/null 2>&1 || [ $attempt -ge 40 ]; do
+			sleep 3
+			attempt=$((attempt+1))
+		done
+		if kubectl wait --for=condition=Available tigerastatus/calico --timeout=180s; then
+			echo "Calico is ready!"
+		else
+			echo "Warning: Timed out waiting for Calico to be ready"
+			kubectl get tigerastatus
+		fi
+	
\ No newline at end of file
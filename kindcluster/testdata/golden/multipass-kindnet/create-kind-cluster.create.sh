@@ -0,0 +1,23 @@
+
+			export DOCKER_HOST=ssh://ubuntu@"$(multipass info myk8s-docker --format csv | awk -F, 'NR == 2 { print $3 }')"
+			cluster_name=myk8s
+
+			# Check if cluster already exists
+			if kind get clusters | grep -qxF "$cluster_name"; then
+				echo "Kind cluster '$cluster_name' already exists"
+			else
+				echo "Creating Kind cluster '$cluster_name'..."
+				kind create cluster --name "$cluster_name" --config ./kind-config.yaml
+				# Kind only reads its config now; keep it to tell later changes apart
+				mkdir -p "$(dirname /home/dev/.local/share/myk8s-cluster/kind/myk8s.yaml)"
+				cp ./kind-config.yaml /home/dev/.local/share/myk8s-cluster/kind/myk8s.yaml
+			fi
+
+			# Verify cluster is accessible
+			if kind get clusters | grep -qxF "$cluster_name"; then
+				echo "Kind cluster '$cluster_name' verified successfully"
+			else
+				echo "ERROR: Failed to create or verify Kind cluster"
+				exit 1
+			fi
+		
\ No newline at end of file
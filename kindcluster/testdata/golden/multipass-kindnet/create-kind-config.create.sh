@@ -0,0 +1,20 @@
+printf '%s' 'kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+nodes:
+  - role: control-plane
+    extraMounts:
+      - hostPath: /tmp/myk8s-control-disk
+        containerPath: /var/lib/disk1
+  - role: worker
+    extraMounts:
+      - hostPath: /tmp/myk8s-worker1-disk
+        containerPath: /var/lib/disk1
+  - role: worker
+    extraMounts:
+      - hostPath: /tmp/myk8s-worker2-disk
+        containerPath: /var/lib/disk1
+  - role: worker
+    extraMounts:
+      - hostPath: /tmp/myk8s-worker3-disk
+        containerPath: /var/lib/disk1
+' > ./kind-config.yaml
\ No newline at end of file
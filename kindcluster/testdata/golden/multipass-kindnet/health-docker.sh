@@ -0,0 +1,8 @@
+
+			export DOCKER_HOST=ssh://ubuntu@"$(multipass info myk8s-docker --format csv | awk -F, 'NR == 2 { print $3 }')"
+			if ! docker ps >/dev/null 2>&1; then
+				echo "Docker is not reachable at $DOCKER_HOST"
+				exit 1
+			fi
+			echo "Docker is reachable at $DOCKER_HOST"
+		
\ No newline at end of file
@@ -0,0 +1,12 @@
+export DOCKER_HOST=ssh://ubuntu@"$(multipass info myk8s-docker --format csv | awk -F, 'NR == 2 { print $3 }')"
+
+		vm_name=myk8s-docker
+		vm_backend=multipass
+		vm_status() {
+			multipass list --format csv 2>/dev/null | awk -F, -v name=myk8s-docker '$1 == name { print $2; found=1 } END { if (!found) print "Missing" }'
+		}
+
+		status=$(vm_status)
+		echo "$vm_backend VM $vm_name is $status"
+		[ "$status" = "Running" ]
+	
\ No newline at end of file
@@ -0,0 +1,47 @@
+export DOCKER_HOST=ssh://ubuntu@"$(multipass info myk8s-docker --format csv | awk -F, 'NR == 2 { print $3 }')"
+
+		vm_name=myk8s-docker
+		vm_backend=multipass
+		vm_status() {
+			multipass list --format csv 2>/dev/null | awk -F, -v name=myk8s-docker '$1 == name { print $2; found=1 } END { if (!found) print "Missing" }'
+		}
+
+		# Create or start the VM depending on its current state
+		case "$(vm_status)" in
+			Running)
+				echo "VM $vm_name is already running"
+				;;
+			Missing)
+				echo "Creating new $vm_backend VM $vm_name..."
+				pubkey=$(cat "$HOME"/.ssh/id_ed25519.pub "$HOME"/.ssh/id_rsa.pub 2>/dev/null | head -n 1)
+				if [ -z "$pubkey" ]; then
+					echo "ERROR: multipass needs an SSH key in ~/.ssh/id_ed25519.pub or ~/.ssh/id_rsa.pub"
+					exit 1
+				fi
+				printf '#cloud-config\npackages: [docker.io]\nssh_authorized_keys: ["%s"]\nruncmd:\n  - usermod -aG docker ubuntu\n' "$pubkey" |
+					multipass launch 24.04 --name myk8s-docker --cpus 8 --memory 2G --disk 500G --cloud-init -
+				;;
+			*)
+				echo "VM $vm_name exists but not running, starting..."
+				multipass start myk8s-docker
+				;;
+		esac
+
+		# Wait for VM to be fully ready with retry logic
+		max_attempts=30
+		attempt=0
+		while [ $attempt -lt $max_attempts ]; do
+			if [ "$(vm_status)" = "Running" ]; then
+				echo "VM $vm_name is ready"
+				break
+			fi
+			echo "Waiting for VM to be ready... (attempt $((attempt+1))/$max_attempts)"
+			sleep 2
+			attempt=$((attempt+1))
+		done
+
+		if [ $attempt -eq $max_attempts ]; then
+			echo "ERROR: VM failed to start after $max_attempts attempts"
+			exit 1
+		fi
+	
\ No newline at end of file
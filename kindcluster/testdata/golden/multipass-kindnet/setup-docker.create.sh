@@ -0,0 +1,7 @@
+
+				docker_context=multipass-myk8s-docker
+				docker context rm "$docker_context" 2>/dev/null || true
+				docker context create "$docker_context" --docker host=ssh://ubuntu@"$(multipass info myk8s-docker --format csv | awk -F, 'NR == 2 { print $3 }')" || true
+				docker context use "$docker_context" || true
+				echo "Current Docker context: $(docker context show)"
+			
\ No newline at end of file
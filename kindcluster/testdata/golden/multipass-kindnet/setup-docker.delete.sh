@@ -0,0 +1,5 @@
+
+				# Reset Docker context to default during cleanup
+				docker context use default 2>/dev/null || true
+				docker context rm multipass-myk8s-docker 2>/dev/null || true
+			
\ No newline at end of file
@@ -0,0 +1,6 @@
+
+			# Disable and remove the systemd user unit
+			systemctl --user disable myk8s-cluster.myk8s.service 2>/dev/null || true
+			rm -f /home/dev/.config/systemd/user/myk8s-cluster.myk8s.service /home/dev/.local/share/myk8s-cluster/myk8s-cluster.myk8s.sh 2>/dev/null || true
+			systemctl --user daemon-reload 2>/dev/null || true
+	
\ No newline at end of file
@@ -0,0 +1,16 @@
+export DOCKER_HOST=unix://"$(podman machine inspect myk8s-docker --format '{{.ConnectionInfo.PodmanSocket.Path}}')"
+
+		vm_name=myk8s-docker
+		vm_backend=podman
+		vm_status() {
+			case "$(podman machine inspect myk8s-docker --format '{{.State}}' 2>/dev/null)" in
+				running) echo Running ;;
+				"") echo Missing ;;
+				*) echo Stopped ;;
+			esac
+		}
+
+		status=$(vm_status)
+		echo "$vm_backend VM $vm_name is $status"
+		[ "$status" = "Running" ]
+	
\ No newline at end of file
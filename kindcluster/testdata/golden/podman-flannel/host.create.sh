@@ -0,0 +1,46 @@
+export DOCKER_HOST=unix://"$(podman machine inspect myk8s-docker --format '{{.ConnectionInfo.PodmanSocket.Path}}')"
+
+		vm_name=myk8s-docker
+		vm_backend=podman
+		vm_status() {
+			case "$(podman machine inspect myk8s-docker --format '{{.State}}' 2>/dev/null)" in
+				running) echo Running ;;
+				"") echo Missing ;;
+				*) echo Stopped ;;
+			esac
+		}
+
+		# Create or start the VM depending on its current state
+		case "$(vm_status)" in
+			Running)
+				echo "VM $vm_name is already running"
+				;;
+			Missing)
+				echo "Creating new $vm_backend VM $vm_name..."
+				podman machine init myk8s-docker --cpus 8 --memory 2048 --disk-size 500 --rootful
+				podman machine start myk8s-docker
+				;;
+			*)
+				echo "VM $vm_name exists but not running, starting..."
+				podman machine start myk8s-docker
+				;;
+		esac
+
+		# Wait for VM to be fully ready with retry logic
+		max_attempts=30
+		attempt=0
+		while [ $attempt -lt $max_attempts ]; do
+			if [ "$(vm_status)" = "Running" ]; then
+				echo "VM $vm_name is ready"
+				break
+			fi
+			echo "Waiting for VM to be ready... (attempt $((attempt+1))/$max_attempts)"
+			sleep 2
+			attempt=$((attempt+1))
+		done
+
+		if [ $attempt -eq $max_attempts ]; then
+			echo "ERROR: VM failed to start after $max_attempts attempts"
+			exit 1
+		fi
+	
\ No newline at end of file
@@ -0,0 +1,11 @@
+export KUBECONFIG=/home/dev/.kube/myk8s-config
+
+		workload=ds/kube-flannel-ds
+		echo "Waiting for $workload in "kube-flannel" to be ready..."
+		if kubectl -n kube-flannel rollout status "$workload" --timeout=120s; then
+			echo "$workload is ready!"
+		else
+			echo "Warning: Timed out waiting for $workload to be ready"
+			kubectl -n kube-flannel get pods -l app=flannel
+		fi
+	
\ No newline at end of file
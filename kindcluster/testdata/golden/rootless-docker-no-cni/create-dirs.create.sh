@@ -0,0 +1 @@
+mkdir -p /tmp/myk8s-control-disk
\ No newline at end of file
@@ -0,0 +1 @@
+rm -f ./kind-config.yaml
\ No newline at end of file
@@ -0,0 +1,8 @@
+export DOCKER_HOST=unix://"${XDG_RUNTIME_DIR:-/run/user/$(id -u)}"/docker.sock
+
+		if ! docker info >/dev/null 2>&1; then
+			echo "Docker daemon at $DOCKER_HOST is not reachable"
+			exit 1
+		fi
+		echo "Docker daemon at $DOCKER_HOST is running"
+	
\ No newline at end of file
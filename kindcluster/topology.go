@@ -0,0 +1,169 @@
+package kindcluster
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"myk8s-cluster/kindconfig"
+)
+
+// NodeSpec holds the settings that can be applied per node or per role.
+type NodeSpec struct {
+	Image       string             `json:"image"`
+	Labels      map[string]string  `json:"labels"`
+	Taints      []string           `json:"taints"` // key[=value]:Effect, as for kubectl taint
+	ExtraMounts []kindconfig.Mount `json:"extraMounts"`
+}
+
+// clusterNode is a single node of the resolved topology.
+type clusterNode struct {
+	Name    string // kind node name without the cluster prefix
+	Role    string
+	DiskDir string // backs /var/lib/disk1 inside the node
+	NodeSpec
+}
+
+// ContainerName is the Docker container (and Kubernetes node) name kind
+// gives this node.
+func (n clusterNode) ContainerName(clusterName string) string {
+	return clusterName + "-" + n.Name
+}
+
+var taintPattern = regexp.MustCompile(`^[A-Za-z0-9][-A-Za-z0-9_./]*(=[-A-Za-z0-9_.]*)?:(NoSchedule|PreferNoSchedule|NoExecute)$`)
+
+// nodes resolves the topology into the node list in kind's naming order:
+// control-plane, control-plane2, ..., worker, worker2, ...
+func (s ClusterSpec) nodes() []clusterNode {
+	var nodes []clusterNode
+	for i := range s.ControlPlanes {
+		nodes = append(nodes, s.node(kindconfig.ControlPlaneRole, "control-plane"+nodeSuffix(i), "control"+nodeSuffix(i), s.ControlPlane))
+	}
+	for i := range s.Workers {
+		nodes = append(nodes, s.node(kindconfig.WorkerRole, "worker"+nodeSuffix(i), "worker"+strconv.Itoa(i+1), s.Worker))
+	}
+	return nodes
+}
+
+func (s ClusterSpec) node(role, name, disk string, defaults NodeSpec) clusterNode {
+	n := clusterNode{
+		Name:    name,
+		Role:    role,
+		DiskDir: nodeDiskPath(s.ClusterName, disk),
+		NodeSpec: NodeSpec{
+			Image:       defaults.Image,
+			Labels:      maps.Clone(defaults.Labels),
+			Taints:      slices.Clone(defaults.Taints),
+			ExtraMounts: slices.Clone(defaults.ExtraMounts),
+		},
+	}
+	if n.Image == "" {
+		n.Image = s.NodeImage
+	}
+	// A cluster without workers has to schedule on its control planes.
+	if role == kindconfig.ControlPlaneRole && s.Workers == 0 {
+		n.Taints = nil
+	}
+
+	override, ok := s.Nodes[name]
+	if !ok {
+		return n
+	}
+	if override.Image != "" {
+		n.Image = override.Image
+	}
+	if len(override.Labels) > 0 {
+		if n.Labels == nil {
+			n.Labels = map[string]string{}
+		}
+		maps.Copy(n.Labels, override.Labels)
+	}
+	if override.Taints != nil {
+		n.Taints = override.Taints
+	}
+	n.ExtraMounts = append(n.ExtraMounts, override.ExtraMounts...)
+	return n
+}
+
+// nodeSuffix mirrors kind's node naming: the first node of a role has no
+// number, the second is "2" and so on.
+func nodeSuffix(i int) string {
+	if i == 0 {
+		return ""
+	}
+	return strconv.Itoa(i + 1)
+}
+
+// nodeDiskPath is the directory on the Docker host that backs /var/lib/disk1
+// inside a node container.
+func nodeDiskPath(clusterName, disk string) string {
+	return "/tmp/" + clusterName + "-" + disk + "-disk"
+}
+
+func (s ClusterSpec) validateTopology() []error {
+	var errs []error
+	if s.ControlPlanes != 1 && s.ControlPlanes != 3 {
+		errs = append(errs, fmt.Errorf("controlPlanes must be 1 or 3 (HA), got %d", s.ControlPlanes))
+	}
+	if s.Workers < 0 {
+		errs = append(errs, fmt.Errorf("workers must not be negative, got %d", s.Workers))
+	}
+
+	known := map[string]bool{}
+	for _, n := range s.nodes() {
+		known[n.Name] = true
+		for _, taint := range n.Taints {
+			if !taintPattern.MatchString(taint) {
+				errs = append(errs, fmt.Errorf("node %s: taint %q must look like key[=value]:NoSchedule|PreferNoSchedule|NoExecute", n.Name, taint))
+			}
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(s.Nodes)) {
+		if !known[name] {
+			errs = append(errs, fmt.Errorf("nodes.%s does not match any node in the topology", name))
+		}
+	}
+	return errs
+}
+
+// nodeHostDirs lists the Docker host directories mounted into the nodes.
+func nodeHostDirs(nodes []clusterNode) []string {
+	var dirs []string
+	for _, n := range nodes {
+		dirs = append(dirs, n.DiskDir)
+		for _, m := range n.ExtraMounts {
+			dirs = append(dirs, m.HostPath)
+		}
+	}
+	return dirs
+}
+
+// nodeTaints are the taints to apply to a node once the cluster is up.
+type nodeTaints struct {
+	Name   string // kind node name without the cluster prefix
+	Node   string
+	Taints []string
+}
+
+// taintedNodes lists the nodes with configured taints.
+func taintedNodes(nodes []clusterNode, clusterName string) []nodeTaints {
+	var tainted []nodeTaints
+	for _, n := range nodes {
+		if len(n.Taints) > 0 {
+			tainted = append(tainted, nodeTaints{Name: n.Name, Node: n.ContainerName(clusterName), Taints: n.Taints})
+		}
+	}
+	return tainted
+}
+
+// parseTaint splits a taint validated by taintPattern into its key, value
+// and effect.
+func parseTaint(taint string) (key, value, effect string) {
+	i := strings.LastIndex(taint, ":")
+	key, effect = taint[:i], taint[i+1:]
+	key, value, _ = strings.Cut(key, "=")
+	return key, value, effect
+}
@@ -0,0 +1,287 @@
+// Package kindconfig models the subset of the kind.x-k8s.io/v1alpha4 Cluster
+// config that this program drives from stack config, and renders it to the
+// YAML file passed to `kind create cluster --config`.
+package kindconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	Kind       = "Cluster"
+	APIVersion = "kind.x-k8s.io/v1alpha4"
+)
+
+// Node roles understood by kind.
+const (
+	ControlPlaneRole = "control-plane"
+	WorkerRole       = "worker"
+)
+
+// Cluster is the top-level kind cluster config.
+type Cluster struct {
+	Kind                    string     `yaml:"kind" json:"kind,omitempty"`
+	APIVersion              string     `yaml:"apiVersion" json:"apiVersion,omitempty"`
+	Name                    string     `yaml:"name,omitempty" json:"name,omitempty"`
+	Networking              Networking `yaml:"networking,omitempty" json:"networking,omitempty"`
+	Nodes                   []Node     `yaml:"nodes" json:"nodes,omitempty"`
+	KubeadmConfigPatches    []string   `yaml:"kubeadmConfigPatches,omitempty" json:"kubeadmConfigPatches,omitempty"`
+	ContainerdConfigPatches []string   `yaml:"containerdConfigPatches,omitempty" json:"containerdConfigPatches,omitempty"`
+}
+
+// Networking holds the cluster-wide network settings.
+type Networking struct {
+	IPFamily          string `yaml:"ipFamily,omitempty" json:"ipFamily,omitempty"`
+	APIServerAddress  string `yaml:"apiServerAddress,omitempty" json:"apiServerAddress,omitempty"`
+	APIServerPort     int    `yaml:"apiServerPort,omitempty" json:"apiServerPort,omitempty"`
+	PodSubnet         string `yaml:"podSubnet,omitempty" json:"podSubnet,omitempty"`
+	ServiceSubnet     string `yaml:"serviceSubnet,omitempty" json:"serviceSubnet,omitempty"`
+	DisableDefaultCNI bool   `yaml:"disableDefaultCNI,omitempty" json:"disableDefaultCNI,omitempty"`
+	KubeProxyMode     string `yaml:"kubeProxyMode,omitempty" json:"kubeProxyMode,omitempty"`
+}
+
+// Node is a single kind node container.
+type Node struct {
+	Role                 string            `yaml:"role" json:"role,omitempty"`
+	Image                string            `yaml:"image,omitempty" json:"image,omitempty"`
+	Labels               map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+	ExtraMounts          []Mount           `yaml:"extraMounts,omitempty" json:"extraMounts,omitempty"`
+	ExtraPortMappings    []PortMapping     `yaml:"extraPortMappings,omitempty" json:"extraPortMappings,omitempty"`
+	KubeadmConfigPatches []string          `yaml:"kubeadmConfigPatches,omitempty" json:"kubeadmConfigPatches,omitempty"`
+}
+
+// Mount binds a path from the Docker host into a node container.
+type Mount struct {
+	HostPath       string `yaml:"hostPath" json:"hostPath"`
+	ContainerPath  string `yaml:"containerPath" json:"containerPath"`
+	ReadOnly       bool   `yaml:"readOnly,omitempty" json:"readOnly,omitempty"`
+	SelinuxRelabel bool   `yaml:"selinuxRelabel,omitempty" json:"selinuxRelabel,omitempty"`
+	Propagation    string `yaml:"propagation,omitempty" json:"propagation,omitempty"`
+}
+
+// PortMapping publishes a node container port on the Docker host.
+type PortMapping struct {
+	ContainerPort int    `yaml:"containerPort" json:"containerPort"`
+	HostPort      int    `yaml:"hostPort,omitempty" json:"hostPort,omitempty"`
+	ListenAddress string `yaml:"listenAddress,omitempty" json:"listenAddress,omitempty"`
+	Protocol      string `yaml:"protocol,omitempty" json:"protocol,omitempty"`
+}
+
+// Render validates the config and returns it as YAML.
+func (c Cluster) Render() ([]byte, error) {
+	if c.Kind == "" {
+		c.Kind = Kind
+	}
+	if c.APIVersion == "" {
+		c.APIVersion = APIVersion
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Parse reads a config as written by Render.
+func Parse(data []byte) (Cluster, error) {
+	var c Cluster
+	err := yaml.Unmarshal(data, &c)
+	return c, err
+}
+
+// Contains reports whether c has everything other sets: its patches and, on
+// the node at the same index, its labels, mounts, port mappings and
+// patches. Settings other leaves empty are not compared.
+func (c Cluster) Contains(other Cluster) bool {
+	if len(other.Nodes) > len(c.Nodes) ||
+		!subset(c.KubeadmConfigPatches, other.KubeadmConfigPatches) ||
+		!subset(c.ContainerdConfigPatches, other.ContainerdConfigPatches) {
+		return false
+	}
+	for i, o := range other.Nodes {
+		n := c.Nodes[i]
+		for k, v := range o.Labels {
+			if value, ok := n.Labels[k]; !ok || value != v {
+				return false
+			}
+		}
+		if !subset(n.ExtraMounts, o.ExtraMounts) ||
+			!subset(n.ExtraPortMappings, o.ExtraPortMappings) ||
+			!subset(n.KubeadmConfigPatches, o.KubeadmConfigPatches) {
+			return false
+		}
+	}
+	return true
+}
+
+// subset reports whether every element of sub is in set.
+func subset[T comparable](set, sub []T) bool {
+	for _, v := range sub {
+		if !slices.Contains(set, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate reports every problem with the config at once.
+func (c Cluster) Validate() error {
+	var errs []error
+	if c.Kind != "" && c.Kind != Kind {
+		errs = append(errs, fmt.Errorf("kind must be %q, got %q", Kind, c.Kind))
+	}
+	if c.APIVersion != "" && c.APIVersion != APIVersion {
+		errs = append(errs, fmt.Errorf("apiVersion must be %q, got %q", APIVersion, c.APIVersion))
+	}
+	errs = append(errs, c.Networking.validate()...)
+
+	controlPlanes := 0
+	for i, n := range c.Nodes {
+		if n.Role == ControlPlaneRole {
+			controlPlanes++
+		}
+		for _, err := range n.validate() {
+			errs = append(errs, fmt.Errorf("nodes[%d]: %w", i, err))
+		}
+	}
+	if controlPlanes == 0 {
+		errs = append(errs, errors.New("at least one control-plane node is required"))
+	}
+	errs = append(errs, c.validateHostPorts()...)
+
+	for i, p := range c.KubeadmConfigPatches {
+		if err := validatePatch(p); err != nil {
+			errs = append(errs, fmt.Errorf("kubeadmConfigPatches[%d]: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n Networking) validate() []error {
+	var errs []error
+	switch n.IPFamily {
+	case "", "ipv4", "ipv6", "dual":
+	default:
+		errs = append(errs, fmt.Errorf("networking.ipFamily must be ipv4, ipv6 or dual, got %q", n.IPFamily))
+	}
+	if n.APIServerAddress != "" && net.ParseIP(n.APIServerAddress) == nil {
+		errs = append(errs, fmt.Errorf("networking.apiServerAddress %q is not an IP address", n.APIServerAddress))
+	}
+	if n.APIServerPort < 0 || n.APIServerPort > 65535 {
+		errs = append(errs, fmt.Errorf("networking.apiServerPort %d is out of range", n.APIServerPort))
+	}
+	if err := validateCIDRs(n.PodSubnet); err != nil {
+		errs = append(errs, fmt.Errorf("networking.podSubnet: %w", err))
+	}
+	if err := validateCIDRs(n.ServiceSubnet); err != nil {
+		errs = append(errs, fmt.Errorf("networking.serviceSubnet: %w", err))
+	}
+	switch n.KubeProxyMode {
+	case "", "iptables", "ipvs", "nftables", "none":
+	default:
+		errs = append(errs, fmt.Errorf("networking.kubeProxyMode must be iptables, ipvs, nftables or none, got %q", n.KubeProxyMode))
+	}
+	return errs
+}
+
+func (n Node) validate() []error {
+	var errs []error
+	if n.Role != ControlPlaneRole && n.Role != WorkerRole {
+		errs = append(errs, fmt.Errorf("role must be %q or %q, got %q", ControlPlaneRole, WorkerRole, n.Role))
+	}
+	for i, m := range n.ExtraMounts {
+		if !path.IsAbs(m.HostPath) || !path.IsAbs(m.ContainerPath) {
+			errs = append(errs, fmt.Errorf("extraMounts[%d]: hostPath and containerPath must be absolute", i))
+		}
+		switch m.Propagation {
+		case "", "None", "HostToContainer", "Bidirectional":
+		default:
+			errs = append(errs, fmt.Errorf("extraMounts[%d]: unknown propagation %q", i, m.Propagation))
+		}
+	}
+	for i, p := range n.ExtraPortMappings {
+		if p.ContainerPort < 1 || p.ContainerPort > 65535 || p.HostPort < 0 || p.HostPort > 65535 {
+			errs = append(errs, fmt.Errorf("extraPortMappings[%d]: port out of range", i))
+		}
+		switch p.Protocol {
+		case "", "TCP", "UDP", "SCTP":
+		default:
+			errs = append(errs, fmt.Errorf("extraPortMappings[%d]: protocol must be TCP, UDP or SCTP, got %q", i, p.Protocol))
+		}
+		if p.ListenAddress != "" && net.ParseIP(p.ListenAddress) == nil {
+			errs = append(errs, fmt.Errorf("extraPortMappings[%d]: listenAddress %q is not an IP address", i, p.ListenAddress))
+		}
+	}
+	for i, p := range n.KubeadmConfigPatches {
+		if err := validatePatch(p); err != nil {
+			errs = append(errs, fmt.Errorf("kubeadmConfigPatches[%d]: %w", i, err))
+		}
+	}
+	return errs
+}
+
+// validateHostPorts rejects two nodes publishing the same host port, which
+// kind would otherwise only report once Docker fails to start a container.
+func (c Cluster) validateHostPorts() []error {
+	var errs []error
+	seen := map[string]bool{}
+	for _, n := range c.Nodes {
+		for _, p := range n.ExtraPortMappings {
+			if p.HostPort == 0 {
+				continue
+			}
+			protocol := p.Protocol
+			if protocol == "" {
+				protocol = "TCP"
+			}
+			key := fmt.Sprintf("%s/%s:%d", p.ListenAddress, protocol, p.HostPort)
+			if seen[key] {
+				errs = append(errs, fmt.Errorf("host port %d is mapped more than once", p.HostPort))
+			}
+			seen[key] = true
+		}
+	}
+	return errs
+}
+
+func validateCIDRs(value string) error {
+	if value == "" {
+		return nil
+	}
+	// dual-stack clusters take a comma-separated IPv4,IPv6 pair
+	for _, cidr := range strings.Split(value, ",") {
+		if _, _, err := net.ParseCIDR(strings.TrimSpace(cidr)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validatePatch checks that a kubeadm patch is a well-formed YAML document.
+func validatePatch(patch string) error {
+	var v map[string]any
+	if err := yaml.Unmarshal([]byte(patch), &v); err != nil {
+		return fmt.Errorf("invalid YAML: %w", err)
+	}
+	if v["kind"] == nil {
+		return errors.New("patch must set kind")
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+// Package kubeconfig merges the context of a cluster kubeconfig into the
+// user's kubeconfig and takes it out again. Every merge leaves a record of
+// the entries it replaced, so Unmerge restores the kubeconfig exactly as it
+// was, while keeping entries the user changed in between.
+package kubeconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// BackupSuffix is appended to the path of a kubeconfig to name the copy of
+// it before the first merge. Other tools leave their own .bak files, so it
+// is named after this program.
+const BackupSuffix = ".myk8s-cluster.bak"
+
+// MergeOptions configures Merge.
+type MergeOptions struct {
+	// From is the kubeconfig to merge, e.g. the one kind exported. Its
+	// current context is merged, with its cluster and user.
+	From string
+	// Data is the content of From if it was read already, or nil.
+	Data []byte
+	// Into is the kubeconfig merged into, by default ~/.kube/config.
+	Into string
+	// Context renames the merged context; the cluster and user keep their
+	// names. Empty keeps the name from From.
+	Context string
+	// Use makes the merged context the current context.
+	Use bool
+	// Record is where the record of the merge is kept for Unmerge.
+	Record string
+}
+
+// Record is what a merge changed: the names it wrote and, for Unmerge to
+// put back, the entries they replaced.
+type Record struct {
+	// Into is the kubeconfig merged into.
+	Into    string `json:"into"`
+	Context string `json:"context"`
+	Cluster string `json:"cluster"`
+	User    string `json:"user"`
+	// Merged is a kubeconfig holding the entries as merged.
+	Merged string `json:"merged"`
+	// Previous is a kubeconfig holding the entries the merge replaced, and
+	// as current context the one before the merge.
+	Previous string `json:"previous"`
+}
+
+// Merge adds the current context of opts.From, with its cluster and user, to
+// opts.Into and writes the record to opts.Record. The contents of opts.Into
+// before it was first written here are kept next to it, see BackupSuffix. A
+// merge recorded earlier is undone first, so merging again is safe.
+func Merge(opts MergeOptions) (*Record, error) {
+	if opts.Record == "" {
+		return nil, errors.New("a record path is needed to undo the merge")
+	}
+	into, err := intoPath(opts.Into)
+	if err != nil {
+		return nil, err
+	}
+	data := opts.Data
+	if data == nil {
+		if data, err = os.ReadFile(opts.From); err != nil {
+			return nil, err
+		}
+	}
+	from, err := clientcmd.Load(data)
+	if err != nil {
+		return nil, fmt.Errorf("loading %s: %w", opts.From, err)
+	}
+	fromContext, ok := from.Contexts[from.CurrentContext]
+	if !ok {
+		return nil, fmt.Errorf("%s has no current context", opts.From)
+	}
+	cluster, ok := from.Clusters[fromContext.Cluster]
+	if !ok {
+		return nil, fmt.Errorf("%s has no cluster %q", opts.From, fromContext.Cluster)
+	}
+	user, ok := from.AuthInfos[fromContext.AuthInfo]
+	if !ok {
+		return nil, fmt.Errorf("%s has no user %q", opts.From, fromContext.AuthInfo)
+	}
+	rec := &Record{
+		Into:    into,
+		Context: from.CurrentContext,
+		Cluster: fromContext.Cluster,
+		User:    fromContext.AuthInfo,
+	}
+	if opts.Context != "" {
+		rec.Context = opts.Context
+	}
+
+	if err := unmergeRecorded(opts.Record); err != nil {
+		return nil, err
+	}
+	unlock, err := lock(into)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err := unlinkFrom(into, opts.From); err != nil {
+		return nil, err
+	}
+	config, err := load(into)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := clientcmdapi.NewConfig()
+	previous.CurrentContext = config.CurrentContext
+	if c, ok := config.Clusters[rec.Cluster]; ok {
+		previous.Clusters[rec.Cluster] = c
+	}
+	if u, ok := config.AuthInfos[rec.User]; ok {
+		previous.AuthInfos[rec.User] = u
+	}
+	if c, ok := config.Contexts[rec.Context]; ok {
+		previous.Contexts[rec.Context] = c
+	}
+	merged := clientcmdapi.NewConfig()
+	merged.Clusters[rec.Cluster] = cluster
+	merged.AuthInfos[rec.User] = user
+	merged.Contexts[rec.Context] = fromContext
+	if rec.Previous, err = encode(previous); err != nil {
+		return nil, err
+	}
+	if rec.Merged, err = encode(merged); err != nil {
+		return nil, err
+	}
+
+	config.Clusters[rec.Cluster] = cluster
+	config.AuthInfos[rec.User] = user
+	config.Contexts[rec.Context] = fromContext
+	if opts.Use || config.CurrentContext == "" {
+		config.CurrentContext = rec.Context
+	}
+	// Keep the record first, so a failed write can still be undone
+	if err := writeRecord(opts.Record, rec); err != nil {
+		return nil, err
+	}
+	if err := write(into, config); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Unmerge undoes the merge recorded at path and removes the record. Entries
+// still as merged are put back as they were before, or removed if there
+// were none; entries changed since are left alone. The current context goes
+// back to the previous one if it is still the merged one. A missing record
+// means there is nothing to undo.
+func Unmerge(path string) error {
+	if err := unmergeRecorded(path); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// ReadRecord reads the record of a merge written to path.
+func ReadRecord(path string) (*Record, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rec Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return &rec, nil
+}
+
+func unmergeRecorded(path string) error {
+	rec, err := ReadRecord(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	merged, err := clientcmd.Load([]byte(rec.Merged))
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	previous, err := clientcmd.Load([]byte(rec.Previous))
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	unlock, err := lock(rec.Into)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	config, err := load(rec.Into)
+	if err != nil {
+		return err
+	}
+	changed := restore(config.Clusters, merged.Clusters, previous.Clusters, rec.Cluster)
+	changed = restore(config.AuthInfos, merged.AuthInfos, previous.AuthInfos, rec.User) || changed
+	changed = restore(config.Contexts, merged.Contexts, previous.Contexts, rec.Context) || changed
+	if config.CurrentContext == rec.Context {
+		config.CurrentContext = ""
+		if _, ok := config.Contexts[previous.CurrentContext]; ok {
+			config.CurrentContext = previous.CurrentContext
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return write(rec.Into, config)
+}
+
+// restore puts back the previous entry under name, or deletes it if there
+// was none, unless it changed since it was merged. It reports whether it
+// did either.
+func restore[T any](entries, merged, previous map[string]*T, name string) bool {
+	current, ok := entries[name]
+	if !ok || !reflect.DeepEqual(current, merged[name]) {
+		return false
+	}
+	if p, ok := previous[name]; ok {
+		entries[name] = p
+	} else {
+		delete(entries, name)
+	}
+	return true
+}
+
+// intoPath is path, or ~/.kube/config if empty.
+func intoPath(path string) (string, error) {
+	if path != "" {
+		return filepath.Abs(path)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, clientcmd.RecommendedHomeDir, clientcmd.RecommendedFileName), nil
+}
+
+// load reads a kubeconfig without resolving its paths, so entries compare
+// equal to what was merged. A missing file is an empty kubeconfig.
+func load(path string) (*clientcmdapi.Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return clientcmdapi.NewConfig(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	config, err := clientcmd.Load(data)
+	if err != nil {
+		return nil, fmt.Errorf("loading %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// write writes config to path. The first write keeps the contents of path
+// in its backup, and later ones leave it alone, so it stays the kubeconfig
+// as it was before any merge rather than the last merged state.
+func write(path string, config *clientcmdapi.Config) error {
+	data, err := clientcmd.Write(*config)
+	if err != nil {
+		return err
+	}
+	if err := backup(path); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// backup copies path to its backup unless that exists or path doesn't.
+func backup(path string) error {
+	if _, err := os.Lstat(path + BackupSuffix); err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	old, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path+BackupSuffix, old, 0o600)
+}
+
+func encode(config *clientcmdapi.Config) (string, error) {
+	data, err := clientcmd.Write(*config)
+	return string(data), err
+}
+
+func writeRecord(path string, rec *Record) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// unlinkFrom removes into if it is a symlink to from, as earlier versions
+// made ~/.kube/config, so merging doesn't write into the cluster kubeconfig.
+func unlinkFrom(into, from string) error {
+	target, err := os.Readlink(into)
+	if err != nil {
+		return nil
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(into), target)
+	}
+	if a, err := filepath.Abs(from); err != nil || filepath.Clean(target) != a {
+		return nil
+	}
+	return os.Remove(into)
+}
+
+// lock takes path.lock, as kubectl does, so concurrent merges into the same
+// kubeconfig don't lose each other's entries.
+func lock(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) || time.Now().After(deadline) {
+			return nil, fmt.Errorf("locking %s: %w", path, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
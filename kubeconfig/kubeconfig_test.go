@@ -0,0 +1,257 @@
+package kubeconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// kindConfig is what kind export kubeconfig writes for a cluster named dev.
+const kindConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: kind-dev
+  cluster:
+    server: https://127.0.0.1:6443
+    certificate-authority-data: Y2E=
+contexts:
+- name: kind-dev
+  context:
+    cluster: kind-dev
+    user: kind-dev
+current-context: kind-dev
+users:
+- name: kind-dev
+  user:
+    client-certificate-data: Y2VydA==
+    client-key-data: a2V5
+`
+
+// userConfig has a context of its own and, under the kind names, an older
+// dev cluster.
+const userConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: work
+  cluster:
+    server: https://work.example.com
+- name: kind-dev
+  cluster:
+    server: https://127.0.0.1:1234
+contexts:
+- name: work
+  context:
+    cluster: work
+    user: work
+current-context: work
+users:
+- name: work
+  user:
+    token: secret
+`
+
+type files struct {
+	from, into, record string
+}
+
+func setup(t *testing.T, into string) files {
+	t.Helper()
+	dir := t.TempDir()
+	f := files{
+		from:   filepath.Join(dir, "dev-config"),
+		into:   filepath.Join(dir, ".kube", "config"),
+		record: filepath.Join(dir, "records", "dev.json"),
+	}
+	writeFile(t, f.from, kindConfig)
+	if into != "" {
+		writeFile(t, f.into, into)
+	}
+	return f
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// normalize loads and writes a kubeconfig, so configs compare equal
+// whatever their formatting.
+func normalize(t *testing.T, content string) string {
+	t.Helper()
+	config, err := clientcmd.Load([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := clientcmd.Write(*config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestMergeUnmerge(t *testing.T) {
+	f := setup(t, userConfig)
+	if _, err := Merge(MergeOptions{From: f.from, Into: f.into, Record: f.record, Use: true}); err != nil {
+		t.Fatal(err)
+	}
+	config, err := clientcmd.LoadFromFile(f.into)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.CurrentContext != "kind-dev" {
+		t.Errorf("current context = %q, want kind-dev", config.CurrentContext)
+	}
+	if got := config.Clusters["kind-dev"].Server; got != "https://127.0.0.1:6443" {
+		t.Errorf("kind-dev server = %s, want the merged one", got)
+	}
+	if config.Contexts["work"] == nil || config.AuthInfos["work"].Token != "secret" {
+		t.Error("the work context or user was lost")
+	}
+	if got := readFile(t, f.into+BackupSuffix); got != userConfig {
+		t.Errorf("backup =\n%s\nwant the previous kubeconfig", got)
+	}
+
+	if err := Unmerge(f.record); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := normalize(t, readFile(t, f.into)), normalize(t, userConfig); got != want {
+		t.Errorf("unmerged kubeconfig =\n%s\nwant\n%s", got, want)
+	}
+	if _, err := os.Stat(f.record); !os.IsNotExist(err) {
+		t.Errorf("record was kept: %v", err)
+	}
+	// Nothing left to undo
+	if err := Unmerge(f.record); err != nil {
+		t.Errorf("second unmerge: %v", err)
+	}
+}
+
+func TestMergeKeepsBackup(t *testing.T) {
+	f := setup(t, userConfig)
+	// Left by another tool, and not the kubeconfig before the merge
+	if err := os.WriteFile(f.into+".bak", []byte("other"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if _, err := Merge(MergeOptions{From: f.from, Into: f.into, Record: f.record, Use: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Unmerge(f.record); err != nil {
+		t.Fatal(err)
+	}
+	// Neither the second merge nor the unmerge replaced the original
+	if got := readFile(t, f.into+BackupSuffix); got != userConfig {
+		t.Errorf("backup =\n%s\nwant the kubeconfig before the first merge", got)
+	}
+	if got := readFile(t, f.into+".bak"); got != "other" {
+		t.Errorf("the .bak of another tool was changed to\n%s", got)
+	}
+}
+
+func TestMergeRename(t *testing.T) {
+	f := setup(t, "")
+	rec, err := Merge(MergeOptions{From: f.from, Into: f.into, Record: f.record, Context: "dev"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Context != "dev" || rec.Cluster != "kind-dev" || rec.User != "kind-dev" {
+		t.Errorf("record names = %s, %s, %s", rec.Context, rec.Cluster, rec.User)
+	}
+	config, err := clientcmd.LoadFromFile(f.into)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The only context becomes the current one even without Use
+	if config.CurrentContext != "dev" || config.Contexts["kind-dev"] != nil {
+		t.Errorf("contexts = %v, current %q, want dev alone", config.Contexts, config.CurrentContext)
+	}
+
+	// Merging again, e.g. after the kubeconfig was exported anew, replaces
+	// the earlier merge rather than recording it as the previous state
+	if _, err := Merge(MergeOptions{From: f.from, Into: f.into, Record: f.record, Context: "dev"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unmerge(f.record); err != nil {
+		t.Fatal(err)
+	}
+	config, err = clientcmd.LoadFromFile(f.into)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Clusters)+len(config.AuthInfos)+len(config.Contexts) != 0 || config.CurrentContext != "" {
+		t.Errorf("unmerged kubeconfig is not empty:\n%s", readFile(t, f.into))
+	}
+}
+
+func TestUnmergeKeepsChanges(t *testing.T) {
+	f := setup(t, userConfig)
+	if _, err := Merge(MergeOptions{From: f.from, Into: f.into, Record: f.record, Use: true}); err != nil {
+		t.Fatal(err)
+	}
+	// The user points the context at another namespace and switches back
+	config, err := clientcmd.LoadFromFile(f.into)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Contexts["kind-dev"].Namespace = "apps"
+	config.CurrentContext = "work"
+	if err := clientcmd.WriteToFile(*config, f.into); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Unmerge(f.record); err != nil {
+		t.Fatal(err)
+	}
+	config, err = clientcmd.LoadFromFile(f.into)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx := config.Contexts["kind-dev"]; ctx == nil || ctx.Namespace != "apps" {
+		t.Errorf("the changed context was not kept: %v", ctx)
+	}
+	if config.AuthInfos["kind-dev"] != nil {
+		t.Error("the unchanged user was kept")
+	}
+	if got := config.Clusters["kind-dev"].Server; got != "https://127.0.0.1:1234" {
+		t.Errorf("kind-dev server = %s, want the one from before the merge", got)
+	}
+	if config.CurrentContext != "work" {
+		t.Errorf("current context = %q, want work", config.CurrentContext)
+	}
+}
+
+func TestMergeReplacesSymlink(t *testing.T) {
+	f := setup(t, "")
+	if err := os.MkdirAll(filepath.Dir(f.into), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(f.from, f.into); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Merge(MergeOptions{From: f.from, Into: f.into, Record: f.record, Context: "dev"}); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(f.into); err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Errorf("%s is still a symlink: %v", f.into, err)
+	}
+	if got := readFile(t, f.from); got != kindConfig {
+		t.Errorf("the merge wrote into the cluster kubeconfig:\n%s", got)
+	}
+}
@@ -0,0 +1,299 @@
+// Package limaconfig models the subset of the Lima instance config
+// (lima.yaml) that this program drives from stack config, and renders it to
+// the YAML file passed to `limactl create`.
+package limaconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Instance is a Lima instance config.
+type Instance struct {
+	VMType       string            `yaml:"vmType,omitempty" json:"vmType,omitempty"`
+	Arch         string            `yaml:"arch,omitempty" json:"arch,omitempty"`
+	Images       []Image           `yaml:"images,omitempty" json:"images,omitempty"`
+	CPUs         int               `yaml:"cpus,omitempty" json:"cpus,omitempty"`
+	Memory       string            `yaml:"memory,omitempty" json:"memory,omitempty"`
+	Disk         string            `yaml:"disk,omitempty" json:"disk,omitempty"`
+	Mounts       []Mount           `yaml:"mounts,omitempty" json:"mounts,omitempty"`
+	MountType    string            `yaml:"mountType,omitempty" json:"mountType,omitempty"`
+	Rosetta      *Rosetta          `yaml:"rosetta,omitempty" json:"rosetta,omitempty"`
+	Containerd   *Containerd       `yaml:"containerd,omitempty" json:"containerd,omitempty"`
+	Provision    []Provision       `yaml:"provision,omitempty" json:"provision,omitempty"`
+	Probes       []Probe           `yaml:"probes,omitempty" json:"probes,omitempty"`
+	PortForwards []PortForward     `yaml:"portForwards,omitempty" json:"portForwards,omitempty"`
+	DNS          []string          `yaml:"dns,omitempty" json:"dns,omitempty"`
+	HostResolver *HostResolver     `yaml:"hostResolver,omitempty" json:"hostResolver,omitempty"`
+	Networks     []Network         `yaml:"networks,omitempty" json:"networks,omitempty"`
+	Env          map[string]string `yaml:"env,omitempty" json:"env,omitempty"`
+}
+
+// Image is a VM disk image for one architecture.
+type Image struct {
+	Location string `yaml:"location" json:"location"`
+	Arch     string `yaml:"arch,omitempty" json:"arch,omitempty"`
+	Digest   string `yaml:"digest,omitempty" json:"digest,omitempty"`
+}
+
+// Mount shares a host directory with the VM.
+type Mount struct {
+	Location   string `yaml:"location" json:"location"`
+	MountPoint string `yaml:"mountPoint,omitempty" json:"mountPoint,omitempty"`
+	Writable   bool   `yaml:"writable,omitempty" json:"writable,omitempty"`
+}
+
+// Rosetta runs amd64 binaries in arm64 vz VMs on Apple silicon.
+type Rosetta struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	BinFmt  bool `yaml:"binfmt,omitempty" json:"binfmt,omitempty"`
+}
+
+// Containerd selects which containerd daemons Lima installs itself.
+type Containerd struct {
+	System bool `yaml:"system" json:"system"`
+	User   bool `yaml:"user" json:"user"`
+}
+
+// Provision is a script run while the VM boots.
+type Provision struct {
+	Mode   string `yaml:"mode" json:"mode"`
+	Script string `yaml:"script" json:"script"`
+}
+
+// Probe is a readiness script `limactl start` waits for.
+type Probe struct {
+	Script string `yaml:"script" json:"script"`
+	Hint   string `yaml:"hint,omitempty" json:"hint,omitempty"`
+}
+
+// PortForward forwards a guest port or socket to the host.
+type PortForward struct {
+	GuestSocket string `yaml:"guestSocket,omitempty" json:"guestSocket,omitempty"`
+	HostSocket  string `yaml:"hostSocket,omitempty" json:"hostSocket,omitempty"`
+	GuestIP     string `yaml:"guestIP,omitempty" json:"guestIP,omitempty"`
+	GuestPort   int    `yaml:"guestPort,omitempty" json:"guestPort,omitempty"`
+	HostIP      string `yaml:"hostIP,omitempty" json:"hostIP,omitempty"`
+	HostPort    int    `yaml:"hostPort,omitempty" json:"hostPort,omitempty"`
+	Proto       string `yaml:"proto,omitempty" json:"proto,omitempty"`
+	Ignore      bool   `yaml:"ignore,omitempty" json:"ignore,omitempty"`
+}
+
+// HostResolver serves DNS to the guest from the host.
+type HostResolver struct {
+	Enabled *bool             `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	Hosts   map[string]string `yaml:"hosts,omitempty" json:"hosts,omitempty"`
+}
+
+// Network attaches an additional network interface to the VM.
+type Network struct {
+	Lima      string `yaml:"lima,omitempty" json:"lima,omitempty"`
+	Socket    string `yaml:"socket,omitempty" json:"socket,omitempty"`
+	VZNAT     bool   `yaml:"vzNAT,omitempty" json:"vzNAT,omitempty"`
+	Interface string `yaml:"interface,omitempty" json:"interface,omitempty"`
+}
+
+// UbuntuImages are the Ubuntu 24.04 cloud images also used by Lima's
+// template:docker.
+var UbuntuImages = []Image{
+	{Location: "https://cloud-images.ubuntu.com/releases/noble/release/ubuntu-24.04-server-cloudimg-amd64.img", Arch: "x86_64"},
+	{Location: "https://cloud-images.ubuntu.com/releases/noble/release/ubuntu-24.04-server-cloudimg-arm64.img", Arch: "aarch64"},
+}
+
+// Docker returns an Ubuntu instance running Docker, rootless like
+// template:docker unless rootful is set. Either way the daemon's socket is
+// forwarded to {{.Dir}}/sock/docker.sock on the host.
+func Docker(rootful bool) Instance {
+	inst := Instance{
+		Images: UbuntuImages,
+		Mounts: []Mount{
+			{Location: "~"},
+			{Location: "/tmp/lima", Writable: true},
+		},
+		Containerd: &Containerd{},
+		HostResolver: &HostResolver{
+			Hosts: map[string]string{"host.docker.internal": "host.lima.internal"},
+		},
+	}
+	if rootful {
+		inst.Provision = []Provision{{Mode: "system", Script: rootfulDockerScript}}
+		inst.Probes = []Probe{{Script: dockerProbeScript("dockerd"), Hint: probeHint}}
+		inst.PortForwards = []PortForward{{GuestSocket: "/var/run/docker.sock", HostSocket: "{{.Dir}}/sock/docker.sock"}}
+	} else {
+		inst.Provision = []Provision{
+			{Mode: "system", Script: rootlessDockerScript},
+			{Mode: "user", Script: rootlessDockerUserScript},
+		}
+		inst.Probes = []Probe{{Script: dockerProbeScript("rootlesskit"), Hint: probeHint}}
+		inst.PortForwards = []PortForward{{GuestSocket: "/run/user/{{.UID}}/docker.sock", HostSocket: "{{.Dir}}/sock/docker.sock"}}
+	}
+	return inst
+}
+
+const rootfulDockerScript = `#!/bin/bash
+set -eux -o pipefail
+command -v docker >/dev/null 2>&1 && exit 0
+export DEBIAN_FRONTEND=noninteractive
+curl -fsSL https://get.docker.com | sh
+# let the Lima user forward the socket without sudo
+mkdir -p /etc/systemd/system/docker.socket.d
+printf '[Socket]\nSocketUser={{.User}}\n' > /etc/systemd/system/docker.socket.d/override.conf
+systemctl daemon-reload
+systemctl restart docker.socket docker
+`
+
+const rootlessDockerScript = `#!/bin/bash
+set -eux -o pipefail
+command -v docker >/dev/null 2>&1 && exit 0
+export DEBIAN_FRONTEND=noninteractive
+curl -fsSL https://get.docker.com | sh
+systemctl disable --now docker
+apt-get install -y uidmap dbus-user-session
+`
+
+const rootlessDockerUserScript = `#!/bin/bash
+set -eux -o pipefail
+systemctl --user start dbus
+dockerd-rootless-setuptool.sh install
+docker context use rootless
+`
+
+const probeHint = `See "/var/log/cloud-init-output.log" in the guest`
+
+func dockerProbeScript(daemon string) string {
+	return fmt.Sprintf(`#!/bin/bash
+set -eux -o pipefail
+if ! timeout 30s bash -c "until command -v docker >/dev/null 2>&1; do sleep 3; done"; then
+  echo >&2 "docker is not installed yet"
+  exit 1
+fi
+if ! timeout 30s bash -c "until pgrep %[1]s; do sleep 3; done"; then
+  echo >&2 "%[1]s is not running"
+  exit 1
+fi
+`, daemon)
+}
+
+// Render validates the config and returns it as YAML.
+func (i Instance) Render() ([]byte, error) {
+	if err := i.Validate(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(i); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Validate reports every problem with the config at once.
+func (i Instance) Validate() error {
+	var errs []error
+	switch i.VMType {
+	case "", "vz", "qemu", "wsl2", "krunkit":
+	default:
+		errs = append(errs, fmt.Errorf("vmType must be vz, qemu, wsl2 or krunkit, got %q", i.VMType))
+	}
+	switch i.Arch {
+	case "", "default", "x86_64", "aarch64", "armv7l", "riscv64":
+	default:
+		errs = append(errs, fmt.Errorf("arch must be x86_64, aarch64, armv7l or riscv64, got %q", i.Arch))
+	}
+	if i.Rosetta != nil && i.Rosetta.Enabled && i.VMType != "vz" {
+		errs = append(errs, errors.New("rosetta requires vmType vz"))
+	}
+	if len(i.Images) == 0 {
+		errs = append(errs, errors.New("at least one image is required"))
+	}
+	for n, img := range i.Images {
+		if img.Location == "" {
+			errs = append(errs, fmt.Errorf("images[%d]: location is required", n))
+		}
+	}
+	switch i.MountType {
+	case "", "reverse-sshfs", "9p", "virtiofs":
+	default:
+		errs = append(errs, fmt.Errorf("mountType must be reverse-sshfs, 9p or virtiofs, got %q", i.MountType))
+	}
+	for n, m := range i.Mounts {
+		if m.Location != "~" && !strings.HasPrefix(m.Location, "~/") && !path.IsAbs(m.Location) {
+			errs = append(errs, fmt.Errorf("mounts[%d]: location %q must be absolute or start with ~", n, m.Location))
+		}
+		if m.MountPoint != "" && !path.IsAbs(m.MountPoint) {
+			errs = append(errs, fmt.Errorf("mounts[%d]: mountPoint %q must be absolute", n, m.MountPoint))
+		}
+	}
+	for n, p := range i.Provision {
+		switch p.Mode {
+		case "system", "user", "boot", "dependency", "data":
+		default:
+			errs = append(errs, fmt.Errorf("provision[%d]: mode must be system, user, boot, dependency or data, got %q", n, p.Mode))
+		}
+		if p.Script == "" {
+			errs = append(errs, fmt.Errorf("provision[%d]: script is required", n))
+		}
+	}
+	for n, p := range i.PortForwards {
+		errs = append(errs, p.validate(n)...)
+	}
+	for n, ip := range i.DNS {
+		if net.ParseIP(ip) == nil {
+			errs = append(errs, fmt.Errorf("dns[%d]: %q is not an IP address", n, ip))
+		}
+	}
+	for n, nw := range i.Networks {
+		set := 0
+		for _, v := range []bool{nw.Lima != "", nw.Socket != "", nw.VZNAT} {
+			if v {
+				set++
+			}
+		}
+		if set != 1 {
+			errs = append(errs, fmt.Errorf("networks[%d]: exactly one of lima, socket or vzNAT must be set", n))
+		}
+		if nw.VZNAT && i.VMType != "vz" {
+			errs = append(errs, fmt.Errorf("networks[%d]: vzNAT requires vmType vz", n))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p PortForward) validate(n int) []error {
+	var errs []error
+	socket := p.GuestSocket != "" || p.HostSocket != ""
+	if socket && (p.GuestSocket == "" || p.HostSocket == "") {
+		errs = append(errs, fmt.Errorf("portForwards[%d]: guestSocket and hostSocket must be set together", n))
+	}
+	if socket && (p.GuestPort != 0 || p.HostPort != 0) {
+		errs = append(errs, fmt.Errorf("portForwards[%d]: sockets and ports cannot be mixed", n))
+	}
+	if !socket && !p.Ignore && p.GuestPort == 0 {
+		errs = append(errs, fmt.Errorf("portForwards[%d]: guestPort or guestSocket is required", n))
+	}
+	if p.GuestPort < 0 || p.GuestPort > 65535 || p.HostPort < 0 || p.HostPort > 65535 {
+		errs = append(errs, fmt.Errorf("portForwards[%d]: port out of range", n))
+	}
+	for _, ip := range []string{p.GuestIP, p.HostIP} {
+		if ip != "" && net.ParseIP(ip) == nil {
+			errs = append(errs, fmt.Errorf("portForwards[%d]: %q is not an IP address", n, ip))
+		}
+	}
+	switch p.Proto {
+	case "", "tcp", "udp", "any":
+	default:
+		errs = append(errs, fmt.Errorf("portForwards[%d]: proto must be tcp, udp or any, got %q", n, p.Proto))
+	}
+	return errs
+}
@@ -0,0 +1,214 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// minVersion is the oldest version of a binary the scripts are known to work
+// with, and the arguments printing its version.
+type minVersion struct {
+	args    []string
+	version string
+}
+
+var minVersions = map[string]minVersion{
+	"kind":      {[]string{"version"}, "0.20.0"},
+	"kubectl":   {[]string{"version", "--client"}, "1.26.0"},
+	"docker":    {[]string{"--version"}, "20.10.0"},
+	"helm":      {[]string{"version", "--short"}, "3.12.0"},
+	"limactl":   {[]string{"--version"}, "1.0.0"},
+	"colima":    {[]string{"version"}, "0.6.0"},
+	"podman":    {[]string{"--version"}, "4.0.0"},
+	"multipass": {[]string{"version"}, "1.12.0"},
+}
+
+var versionPattern = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// Binaries checks that each binary is on PATH and, for those with a known
+// minimum, not older than it. Versions that can't be told are not checked.
+func Binaries(names ...string) Check {
+	return Func("binaries", func(ctx context.Context) error {
+		var errs []error
+		for _, name := range slices.Compact(slices.Sorted(slices.Values(names))) {
+			if err := checkBinary(ctx, name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+func checkBinary(ctx context.Context, name string) error {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return fmt.Errorf("%s not found on PATH", name)
+	}
+	want, ok := minVersions[name]
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	out, _ := exec.CommandContext(ctx, path, want.args...).CombinedOutput()
+	got := versionPattern.FindString(string(out))
+	if got == "" {
+		return nil
+	}
+	if compareVersions(got, want.version) < 0 {
+		return fmt.Errorf("%s %s is older than the required %s", name, got, want.version)
+	}
+	return nil
+}
+
+// compareVersions compares dotted versions numerically, missing parts
+// counting as 0.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := range max(len(as), len(bs)) {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
+
+// Memory checks that requestGB is less than the host's physical memory.
+func Memory(requestGB int) Check {
+	return Func("memory", func(context.Context) error {
+		if hostGB := hostMemoryGB(); hostGB > 0 && requestGB >= hostGB {
+			return fmt.Errorf("memory %dGB must be less than the host's %dGB", requestGB, hostGB)
+		}
+		return nil
+	})
+}
+
+// sparseDiskGB is the most of a VM disk that must be free up front. Disk
+// images are sparse, so the rest is only used as the cluster fills it.
+const sparseDiskGB = 20
+
+// Disk checks that the filesystem holding dir has room for a VM disk of
+// requestGB: all of it if small, else the first sparseDiskGB.
+func Disk(dir string, requestGB int) Check {
+	return Func("disk", func(context.Context) error {
+		need := min(requestGB, sparseDiskGB)
+		existing := existingDir(dir)
+		if free := freeDiskGB(existing); free >= 0 && free < need {
+			return fmt.Errorf("%dGB free in %s, the %dGB VM disk needs at least %dGB", free, existing, requestGB, need)
+		}
+		return nil
+	})
+}
+
+// Port is a port the cluster publishes on this machine.
+type Port struct {
+	// Address is the listen address, e.g. 127.0.0.1 or 0.0.0.0.
+	Address  string
+	Port     int
+	Protocol string // tcp or udp
+}
+
+func (p Port) String() string {
+	return fmt.Sprintf("%s/%s", net.JoinHostPort(p.Address, strconv.Itoa(p.Port)), p.Protocol)
+}
+
+// Ports checks that nothing listens on the ports yet. Addresses this machine
+// can't listen on, e.g. the IP of a VM, are skipped.
+func Ports(ports ...Port) Check {
+	return Func("ports", func(context.Context) error {
+		var errs []error
+		for _, p := range ports {
+			if err := listen(p); errors.Is(err, syscall.EADDRINUSE) {
+				errs = append(errs, fmt.Errorf("%s is already in use", p))
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+func listen(p Port) error {
+	addr := net.JoinHostPort(p.Address, strconv.Itoa(p.Port))
+	if p.Protocol == "udp" {
+		conn, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
+
+// Writable checks that the paths can be written: existing files opened for
+// writing, and files created in existing directories or, for missing paths,
+// in their closest existing parent.
+func Writable(paths ...string) Check {
+	return Func("paths", func(context.Context) error {
+		var errs []error
+		for _, path := range slices.Compact(slices.Sorted(slices.Values(paths))) {
+			if err := writable(path); err != nil {
+				// The path in the error may be a temporary file
+				var pathErr *fs.PathError
+				if errors.As(err, &pathErr) {
+					err = pathErr.Err
+				}
+				errs = append(errs, fmt.Errorf("%s is not writable: %w", path, err))
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+func writable(path string) error {
+	info, err := os.Stat(path)
+	if err == nil && !info.IsDir() {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	}
+	f, err := os.CreateTemp(existingDir(path), ".preflight-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// existingDir is path if it exists, else its closest existing parent.
+func existingDir(path string) string {
+	path = filepath.Clean(path)
+	for {
+		if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package preflight
+
+// freeDiskGB is not implemented on this platform; -1 disables the check.
+func freeDiskGB(string) int {
+	return -1
+}
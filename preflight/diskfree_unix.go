@@ -0,0 +1,15 @@
+//go:build unix
+
+package preflight
+
+import "golang.org/x/sys/unix"
+
+// freeDiskGB returns the space available to this user on the filesystem
+// holding path in GB, or -1 if it cannot be determined.
+func freeDiskGB(path string) int {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return -1
+	}
+	return int(uint64(stat.Bavail) * uint64(stat.Bsize) >> 30)
+}
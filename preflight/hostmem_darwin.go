@@ -0,0 +1,13 @@
+package preflight
+
+import "golang.org/x/sys/unix"
+
+// hostMemoryGB returns the physical memory of the host in GB, or 0 if it
+// cannot be determined.
+func hostMemoryGB() int {
+	bytes, err := unix.SysctlUint64("hw.memsize")
+	if err != nil {
+		return 0
+	}
+	return int(bytes >> 30)
+}
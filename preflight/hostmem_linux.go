@@ -0,0 +1,13 @@
+package preflight
+
+import "golang.org/x/sys/unix"
+
+// hostMemoryGB returns the physical memory of the host in GB, or 0 if it
+// cannot be determined.
+func hostMemoryGB() int {
+	var info unix.Sysinfo_t
+	if err := unix.Sysinfo(&info); err != nil {
+		return 0
+	}
+	return int(uint64(info.Totalram) * uint64(info.Unit) >> 30)
+}
@@ -0,0 +1,8 @@
+//go:build !darwin && !linux
+
+package preflight
+
+// hostMemoryGB is not implemented on this platform; 0 disables the check.
+func hostMemoryGB() int {
+	return 0
+}
@@ -0,0 +1,95 @@
+// Package preflight checks that this machine can run the cluster before any
+// resource is created: the binaries the scripts run and their versions, the
+// host's memory and disk, free ports, what already exists in the way and the
+// paths to write. Every check runs and every problem is reported at once,
+// rather than the first one surfacing midway through a script.
+package preflight
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Check is a single preflight check.
+type Check interface {
+	// Name identifies the check in the report, e.g. "binaries".
+	Name() string
+	// Run returns every problem found, joined with errors.Join, or nil.
+	Run(ctx context.Context) error
+}
+
+// Func returns a Check calling run.
+func Func(name string, run func(context.Context) error) Check {
+	return &funcCheck{name: name, run: run}
+}
+
+type funcCheck struct {
+	name string
+	run  func(context.Context) error
+}
+
+func (c *funcCheck) Name() string                  { return c.name }
+func (c *funcCheck) Run(ctx context.Context) error { return c.run(ctx) }
+
+// Run runs the checks concurrently and reports every problem, grouped by
+// check in the order given, or nil.
+func Run(ctx context.Context, checks ...Check) error {
+	errs := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = check.Run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	var problems []string
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		for _, line := range strings.Split(err.Error(), "\n") {
+			problems = append(problems, fmt.Sprintf("%s: %s", checks[i].Name(), line))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("preflight checks failed:\n%s", strings.Join(problems, "\n"))
+}
+
+// Script checks by running a shell script on this machine. The check fails
+// if the script exits non-zero, with its last line of output as the problem.
+func Script(name, script string) Check {
+	return Func(name, func(ctx context.Context) error {
+		_, err := Output(ctx, script)
+		return err
+	})
+}
+
+// Output runs a shell script and returns its output. If it exits non-zero
+// the error is its last line of output.
+func Output(ctx context.Context, script string) (string, error) {
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", script)
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	if err := cmd.Run(); err != nil {
+		if msg := lastLine(out.String()); msg != "" {
+			return out.String(), errors.New(msg)
+		}
+		return out.String(), err
+	}
+	return out.String(), nil
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	return s[strings.LastIndex(s, "\n")+1:]
+}
@@ -0,0 +1,142 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	err := Run(context.Background(),
+		Func("ok", func(context.Context) error { return nil }),
+		Func("binaries", func(context.Context) error {
+			return errors.Join(errors.New("kind not found on PATH"), errors.New("helm not found on PATH"))
+		}),
+		Func("memory", func(context.Context) error { return errors.New("too much") }),
+	)
+	want := "preflight checks failed:\n" +
+		"binaries: kind not found on PATH\n" +
+		"binaries: helm not found on PATH\n" +
+		"memory: too much"
+	if err == nil || err.Error() != want {
+		t.Errorf("err = %v, want:\n%s", err, want)
+	}
+	if err := Run(context.Background(), Func("ok", func(context.Context) error { return nil })); err != nil {
+		t.Errorf("passing checks: err = %v", err)
+	}
+}
+
+func TestBinaries(t *testing.T) {
+	dir := t.TempDir()
+	for name, out := range map[string]string{
+		"kind":    "kind v0.19.0 go1.20 linux/amd64",
+		"kubectl": "Client Version: v1.31.2",
+		"helm":    "no version here",
+		"custom":  "",
+	} {
+		script := "#!/bin/sh\necho '" + out + "'\n"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+
+	err := Binaries("kubectl", "kind", "helm", "custom", "missing", "kind").Run(context.Background())
+	want := "kind 0.19.0 is older than the required 0.20.0\nmissing not found on PATH"
+	if err == nil || err.Error() != want {
+		t.Errorf("err = %v, want:\n%s", err, want)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.26.0", "1.26.0", 0},
+		{"1.26", "1.26.0", 0},
+		{"1.9.0", "1.26.0", -1},
+		{"20.10.1", "20.10.0", 1},
+		{"1.0.0", "0.20.0", 1},
+	} {
+		got := compareVersions(c.a, c.b)
+		if (got < 0 && c.want >= 0) || (got > 0 && c.want <= 0) || (got == 0 && c.want != 0) {
+			t.Errorf("compareVersions(%s, %s) = %d, want sign of %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestPorts(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	used := l.Addr().(*net.TCPAddr).Port
+
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unused := free.Addr().(*net.TCPAddr).Port
+	free.Close()
+
+	err = Ports(
+		Port{Address: "127.0.0.1", Port: used, Protocol: "tcp"},
+		Port{Address: "127.0.0.1", Port: unused, Protocol: "tcp"},
+		// Not an address of this machine
+		Port{Address: "192.0.2.1", Port: used, Protocol: "tcp"},
+	).Run(context.Background())
+	want := Port{Address: "127.0.0.1", Port: used, Protocol: "tcp"}.String() + " is already in use"
+	if err == nil || err.Error() != want {
+		t.Errorf("err = %v, want %s", err, want)
+	}
+}
+
+func TestWritable(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "config")
+	if err := os.WriteFile(existing, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	readOnly := filepath.Join(dir, "read-only")
+	if err := os.Mkdir(readOnly, 0o555); err != nil {
+		t.Fatal(err)
+	}
+
+	err := Writable(existing, filepath.Join(dir, "missing", "dir", "file")).Run(context.Background())
+	if err != nil {
+		t.Errorf("writable paths: err = %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("checking left files behind: %v", entries)
+	}
+
+	if os.Getuid() == 0 {
+		t.Skip("root can write anywhere")
+	}
+	path := filepath.Join(readOnly, "kubeconfig")
+	err = Writable(path).Run(context.Background())
+	if err == nil || !strings.HasPrefix(err.Error(), path+" is not writable: ") {
+		t.Errorf("read-only directory: err = %v", err)
+	}
+}
+
+func TestScript(t *testing.T) {
+	out, err := Output(context.Background(), "echo one; echo two")
+	if err != nil || out != "one\ntwo\n" {
+		t.Errorf("Output = %q, %v", out, err)
+	}
+	err = Script("host", "echo checking; echo 'VM is Broken' >&2; exit 1").Run(context.Background())
+	if err == nil || err.Error() != "VM is Broken" {
+		t.Errorf("err = %v, want the last line of output", err)
+	}
+	err = Script("host", "exit 3").Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("err = %v, want the exit status", err)
+	}
+}
@@ -0,0 +1,78 @@
+// Package project is the Pulumi program of the myk8s-cluster project. The
+// pulumi CLI runs it through main, and kindctl runs it inline through the
+// Automation API.
+package project
+
+import (
+	"myk8s-cluster/kindcluster"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Name is the Pulumi project name, which also namespaces its config keys.
+const Name = "myk8s-cluster"
+
+// Program is the Pulumi program: it loads the configuration, registers the
+// cluster and exports the stack outputs.
+func Program(ctx *pulumi.Context) error {
+	// Load and validate configuration before registering any resources
+	spec, err := kindcluster.LoadClusterSpec(ctx)
+	if err != nil {
+		return err
+	}
+
+	outputs, err := Deploy(ctx, spec)
+	if err != nil {
+		return err
+	}
+	for name, value := range outputs {
+		ctx.Export(name, value)
+	}
+	return nil
+}
+
+// Deploy registers the clusters described by spec and returns the stack
+// outputs: those of the first cluster, every cluster under clusters keyed by
+// name, and their kubeconfigs, as a secret, under kubeconfigs.
+func Deploy(ctx *pulumi.Context, spec kindcluster.ClusterSpec) (pulumi.Map, error) {
+	clusters, err := kindcluster.NewKindClusters(ctx, &kindcluster.KindClusterArgs{
+		ClusterSpec:          spec,
+		AdoptLegacyResources: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byName := pulumi.Map{}
+	kubeconfigs := pulumi.Map{}
+	for _, cluster := range clusters {
+		// A secret anywhere in a map makes the whole map secret, so the
+		// kubeconfigs are kept apart
+		byName[cluster.Name()] = clusterOutputs(cluster)
+		kubeconfigs[cluster.Name()] = cluster.Kubeconfig
+	}
+	first := clusters[0]
+	outputs := clusterOutputs(first)
+	outputs["clusterName"] = first.ClusterName
+	outputs["kubeconfig"] = first.Kubeconfig
+	outputs["clusters"] = byName
+	outputs["kubeconfigs"] = pulumi.ToSecret(kubeconfigs)
+	return outputs, nil
+}
+
+// clusterOutputs are the outputs of a cluster other stacks can consume,
+// except its kubeconfig.
+func clusterOutputs(cluster *kindcluster.KindCluster) pulumi.Map {
+	return pulumi.Map{
+		"kubeconfigPath":           cluster.KubeconfigPath,
+		"contextName":              cluster.ContextName,
+		"endpoint":                 cluster.Endpoint,
+		"certificateAuthorityData": cluster.CertificateAuthorityData,
+		"nodes":                    cluster.Nodes,
+		"kubernetesVersion":        cluster.KubernetesVersion,
+		"dockerHost":               cluster.DockerHost,
+		"vmName":                   cluster.VMName,
+		"healthReport":             cluster.HealthReport,
+		"addons":                   cluster.Addons,
+	}
+}
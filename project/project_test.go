@@ -0,0 +1,803 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"myk8s-cluster/addons"
+	"myk8s-cluster/kindcluster"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/internals"
+)
+
+// testKubeconfig is what the mocked read-kubeconfig step prints.
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: kind-dev
+  cluster:
+    server: https://127.0.0.1:6443
+    certificate-authority-data: Y2E=
+contexts:
+- name: kind-dev
+  context:
+    cluster: kind-dev
+    user: kind-dev
+current-context: kind-dev
+users:
+- name: kind-dev
+  user:
+    client-certificate-data: Y2VydA==
+    client-key-data: a2V5
+`
+
+// testClusterInfo is what the mocked read-cluster-info step prints.
+const testClusterInfo = `docker-host unix:///var/run/docker.sock
+node dev-control-plane v1.31.2 172.18.0.3 fc00:f853:ccd:e793::3
+node dev-worker v1.31.2 172.18.0.2
+`
+
+// mockResource is a resource registered with the mocks.
+type mockResource struct {
+	Type      string
+	Inputs    resource.PropertyMap
+	DependsOn []string
+	// Provider is the name of its provider, if set explicitly.
+	Provider string
+}
+
+// mocks records every resource the program registers, keyed by its name
+// without the cluster prefix, and answers read-kubeconfig with
+// testKubeconfig and read-cluster-info with testClusterInfo.
+type mocks struct {
+	prefix string
+
+	mu        sync.Mutex
+	resources map[string]mockResource
+	// secrets are the names of the stack outputs that are secret.
+	secrets map[string]bool
+}
+
+func (m *mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	var deps []string
+	for _, urn := range args.RegisterRPC.GetDependencies() {
+		deps = append(deps, strings.TrimPrefix(resource.URN(urn).Name(), m.prefix))
+	}
+	name := strings.TrimPrefix(args.Name, m.prefix)
+	// Provider references are the provider's URN and ID joined by ::
+	var provider string
+	if i := strings.LastIndex(args.Provider, "::"); i >= 0 {
+		provider = strings.TrimPrefix(resource.URN(args.Provider[:i]).Name(), m.prefix)
+	}
+
+	m.mu.Lock()
+	m.resources[name] = mockResource{Type: args.TypeToken, Inputs: args.Inputs, DependsOn: deps, Provider: provider}
+	m.mu.Unlock()
+
+	outputs := args.Inputs.Copy()
+	if args.TypeToken == "command:local:Command" {
+		stdout := ""
+		switch {
+		case strings.HasSuffix(name, "read-kubeconfig"):
+			stdout = testKubeconfig
+		case strings.HasSuffix(name, "read-cluster-info"):
+			stdout = testClusterInfo
+		}
+		outputs["stdout"] = resource.NewStringProperty(stdout)
+	}
+	return args.Name + "-id", outputs, nil
+}
+
+func (m *mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	return args.Args, nil
+}
+
+// names lists the registered resources.
+func (m *mocks) names() []string {
+	var names []string
+	for name := range m.resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// script returns an input of a command, e.g. its "create" script.
+func (m *mocks) script(t *testing.T, name, input string) string {
+	t.Helper()
+	r, ok := m.resources[name]
+	if !ok {
+		t.Fatalf("no resource %q, have %v", name, m.names())
+	}
+	v, ok := r.Inputs[resource.PropertyKey(input)]
+	if !ok {
+		return ""
+	}
+	return v.StringValue()
+}
+
+// inputs returns the inputs of a resource as plain values.
+func (m *mocks) inputs(t *testing.T, name string) map[string]any {
+	t.Helper()
+	r, ok := m.resources[name]
+	if !ok {
+		t.Fatalf("no resource %q, have %v", name, m.names())
+	}
+	return r.Inputs.Mappable()
+}
+
+// testSpec is the default spec on the given host, small enough to pass the
+// host memory check, with a home directory of its own.
+func testSpec(t *testing.T, hostName string) kindcluster.ClusterSpec {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	spec := kindcluster.DefaultClusterSpec()
+	spec.Host = hostName
+	spec.ClusterName = "dev"
+	spec.Memory = 2
+	spec.Autostart = "none"
+	spec.Preflight = kindcluster.PreflightOff
+	spec.HealthCheck = kindcluster.HealthCheckOff
+	return spec
+}
+
+// runDeploy runs Deploy against the mocks and returns them with the
+// resolved stack outputs.
+func runDeploy(t *testing.T, spec kindcluster.ClusterSpec) (*mocks, map[string]any) {
+	t.Helper()
+	m := &mocks{prefix: spec.ClusterName + "-", resources: map[string]mockResource{}, secrets: map[string]bool{}}
+	outputs := map[string]any{}
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		out, err := Deploy(ctx, spec)
+		if err != nil {
+			return err
+		}
+		// Outputs resolve asynchronously, so wait for them before the
+		// program returns
+		for name, value := range out {
+			result, err := internals.UnsafeAwaitOutput(ctx.Context(), pulumi.ToOutput(value))
+			if err != nil {
+				return err
+			}
+			outputs[name] = result.Value
+			m.secrets[name] = result.Secret
+		}
+		return nil
+	}, pulumi.WithMocks(Name, "test", m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, outputs
+}
+
+func TestResources(t *testing.T) {
+	tests := []struct {
+		name string
+		spec func(t *testing.T) kindcluster.ClusterSpec
+		want []string
+	}{
+		{
+			name: "lima vm with calico",
+			spec: func(t *testing.T) kindcluster.ClusterSpec {
+				spec := testSpec(t, "vm")
+				spec.Autostart = "systemd"
+				return spec
+			},
+			want: []string{
+				"autostart", "cni", "cni-vxlan", "create-dirs", "create-kind-cluster",
+				"create-kind-config", "dev", "export-kubeconfig", "host", "host-config",
+				"install-cni", "k8s-provider", "merge-kubeconfig", "read-cluster-info",
+				"read-kubeconfig", "resize-host", "setup-docker", "taint-control-plane",
+				"update-shell-profiles", "wait-for-cni",
+			},
+		},
+		{
+			name: "colima vm without autostart",
+			spec: func(t *testing.T) kindcluster.ClusterSpec {
+				spec := testSpec(t, "vm")
+				spec.VMBackend = "colima"
+				return spec
+			},
+			want: []string{
+				"cni", "cni-vxlan", "create-dirs", "create-kind-cluster", "create-kind-config",
+				"dev", "export-kubeconfig", "host", "install-cni", "k8s-provider",
+				"merge-kubeconfig", "read-cluster-info", "read-kubeconfig", "resize-host",
+				"setup-docker", "taint-control-plane", "update-shell-profiles", "wait-for-cni",
+			},
+		},
+		{
+			name: "docker with kindnet",
+			spec: func(t *testing.T) kindcluster.ClusterSpec {
+				spec := testSpec(t, "docker")
+				spec.CNI = "kindnet"
+				return spec
+			},
+			want: []string{
+				"create-dirs", "create-kind-cluster", "create-kind-config", "dev",
+				"export-kubeconfig", "host", "k8s-provider", "merge-kubeconfig",
+				"read-cluster-info", "read-kubeconfig", "taint-control-plane",
+				"update-shell-profiles", "wait-for-cni",
+			},
+		},
+		{
+			name: "docker with cilium and no taints",
+			spec: func(t *testing.T) kindcluster.ClusterSpec {
+				spec := testSpec(t, "docker")
+				spec.CNI = "cilium"
+				spec.ControlPlane.Taints = nil
+				return spec
+			},
+			want: []string{
+				"cni", "create-dirs", "create-kind-cluster", "create-kind-config", "dev",
+				"export-kubeconfig", "host", "install-cni", "k8s-provider", "merge-kubeconfig",
+				"read-cluster-info", "read-kubeconfig", "update-shell-profiles", "wait-for-cni",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := runDeploy(t, tt.spec(t))
+			if got := m.names(); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("resources:\n got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDependsOn(t *testing.T) {
+	spec := testSpec(t, "vm")
+	spec.Autostart = "systemd"
+	m, _ := runDeploy(t, spec)
+
+	want := map[string][]string{
+		"host":                  {"create-dirs", "create-kind-config", "host-config"},
+		"resize-host":           {"host"},
+		"autostart":             {"host"},
+		"setup-docker":          {"host"},
+		"create-kind-cluster":   {"autostart", "host", "resize-host", "setup-docker"},
+		"export-kubeconfig":     {"create-kind-cluster"},
+		"update-shell-profiles": {"export-kubeconfig"},
+		"read-kubeconfig":       {"export-kubeconfig"},
+		"merge-kubeconfig":      {"read-kubeconfig"},
+		"k8s-provider":          {"read-kubeconfig"},
+		"cni-vxlan":             {"cni"},
+		"wait-for-cni":          {"export-kubeconfig", "cni", "cni-vxlan"},
+	}
+	for name, deps := range want {
+		got := map[string]bool{}
+		for _, dep := range m.resources[name].DependsOn {
+			got[dep] = true
+		}
+		for _, dep := range deps {
+			if !got[dep] {
+				t.Errorf("%s: missing dependency on %s, have %v", name, dep, m.resources[name].DependsOn)
+			}
+		}
+	}
+	// The first steps only touch the local filesystem and wait for nothing
+	for _, dep := range m.resources["create-dirs"].DependsOn {
+		t.Errorf("create-dirs: unexpected dependency on %s", dep)
+	}
+}
+
+func TestInClusterResources(t *testing.T) {
+	spec := testSpec(t, "docker")
+	spec.Nodes = map[string]kindcluster.NodeSpec{"worker": {Taints: []string{"dedicated=db:NoExecute", "gpu:PreferNoSchedule"}}}
+	m, _ := runDeploy(t, spec)
+
+	tests := []struct {
+		resource, typ string
+		want          []string
+	}{
+		{"cni", "kubernetes:yaml/v2:ConfigGroup", []string{"calico/v3.29.1/manifests/calico.yaml"}},
+		{"cni-vxlan", "kubernetes:apps/v1:DaemonSetPatch", []string{"name:calico-node", "name:CALICO_IPV4POOL_VXLAN value:Always"}},
+		{"taint-control-plane", "kubernetes:core/v1:NodePatch", []string{"name:dev-control-plane",
+			"map[effect:NoSchedule key:node-role.kubernetes.io/control-plane value:]"}},
+		{"taint-worker", "kubernetes:core/v1:NodePatch", []string{"name:dev-worker",
+			"[map[effect:NoExecute key:dedicated value:db] map[effect:PreferNoSchedule key:gpu value:]]"}},
+	}
+	for _, tt := range tests {
+		r := m.resources[tt.resource]
+		if r.Type != tt.typ || r.Provider != "k8s-provider" {
+			t.Errorf("%s: type %q with provider %q, want %s with k8s-provider", tt.resource, r.Type, r.Provider, tt.typ)
+		}
+		inputs := fmt.Sprint(m.inputs(t, tt.resource))
+		for _, want := range tt.want {
+			if !strings.Contains(inputs, want) {
+				t.Errorf("%s inputs do not contain %q: %s", tt.resource, want, inputs)
+			}
+		}
+	}
+	if _, ok := m.resources["taint-worker2"]; ok {
+		t.Error("untainted worker2 is patched")
+	}
+
+	// Cilium is a Helm release, created after the release the helm CLI
+	// installed is uninstalled
+	spec = testSpec(t, "docker")
+	spec.CNI = "cilium"
+	m, _ = runDeploy(t, spec)
+	if r := m.resources["cni"]; r.Type != "kubernetes:helm.sh/v3:Release" || !slices.Contains(r.DependsOn, "install-cni") {
+		t.Errorf("cni: type %q depending on %v, want a Release after install-cni", r.Type, r.DependsOn)
+	}
+	if script := m.script(t, "install-cni", "update"); !strings.Contains(script, "helm uninstall cilium") {
+		t.Errorf("install-cni does not uninstall the helm CLI release:\n%s", script)
+	}
+	if script := m.script(t, "install-cni", "delete"); script != "" {
+		t.Errorf("install-cni deletes:\n%s", script)
+	}
+}
+
+func TestAddons(t *testing.T) {
+	spec := testSpec(t, "docker")
+	spec.Addons = map[string]addons.Settings{
+		"cert-manager":   {},
+		"ingress-nginx":  {},
+		"metallb":        {},
+		"metrics-server": {Enabled: new(bool)},
+	}
+	m, _ := runDeploy(t, spec)
+
+	tests := []struct {
+		resource, typ string
+		deps          []string
+	}{
+		{"addon-cert-manager", "kubernetes:helm.sh/v3:Release", []string{"wait-for-cni", "teardown-cert-manager"}},
+		{"addon-ingress-nginx", "kubernetes:yaml/v2:ConfigGroup", []string{"wait-for-cni"}},
+		{"addon-metallb", "kubernetes:yaml/v2:ConfigGroup", []string{"wait-for-cni"}},
+		{"addon-metallb-pool", "kubernetes:yaml/v2:ConfigGroup", []string{"addon-metallb"}},
+		{"wait-for-metallb", "command:local:Command", []string{"export-kubeconfig", "addon-metallb", "addon-metallb-pool"}},
+	}
+	for _, tt := range tests {
+		r, ok := m.resources[tt.resource]
+		if !ok {
+			t.Errorf("no resource %s, have %v", tt.resource, m.names())
+			continue
+		}
+		if r.Type != tt.typ {
+			t.Errorf("%s: type %q, want %s", tt.resource, r.Type, tt.typ)
+		}
+		for _, dep := range tt.deps {
+			if !slices.Contains(r.DependsOn, dep) {
+				t.Errorf("%s: missing dependency on %s, have %v", tt.resource, dep, r.DependsOn)
+			}
+		}
+	}
+	for _, name := range []string{"addon-metrics-server", "wait-for-metrics-server"} {
+		if _, ok := m.resources[name]; ok {
+			t.Errorf("disabled add-on registered %s", name)
+		}
+	}
+	// The pool defaults to a range of the node network
+	if pool := fmt.Sprint(m.inputs(t, "addon-metallb-pool")); !strings.Contains(pool, "172.18.255.200-172.18.255.250") {
+		t.Errorf("addon-metallb-pool is not on the node network: %s", pool)
+	}
+	if script := m.script(t, "teardown-cert-manager", "delete"); !strings.Contains(script, "kubectl delete namespace cert-manager") {
+		t.Errorf("teardown-cert-manager does not delete the namespace:\n%s", script)
+	}
+
+	checks, err := spec.HealthChecks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, check := range checks {
+		names = append(names, check.Name())
+	}
+	if got := strings.Join(names, " "); !strings.HasSuffix(got, "coredns cert-manager ingress-nginx metallb") {
+		t.Errorf("health checks = %s, want the add-ons last", got)
+	}
+}
+
+func TestAddonsInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings addons.Settings
+		want     string
+	}{
+		{"traefik", addons.Settings{}, `unknown add-on "traefik"`},
+		{"metallb", addons.Settings{Addresses: []string{"10.0.0.1"}}, `addons.metallb: addresses "10.0.0.1" must be a CIDR or a first-last IP range`},
+		{"registry", addons.Settings{Port: 70000}, "addons.registry: port 70000 is out of range"},
+	}
+	for _, tt := range tests {
+		spec := testSpec(t, "docker")
+		spec.Addons = map[string]addons.Settings{tt.name: tt.settings}
+		if err := spec.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("error does not mention %q: %v", tt.want, err)
+		}
+	}
+
+	// The ports of the add-ons are claimed like those of extraPortMappings
+	spec := testSpec(t, "docker")
+	spec.Clusters = []json.RawMessage{
+		json.RawMessage(`{"clusterName": "a", "addons": {"ingress-nginx": {}}}`),
+		json.RawMessage(`{"clusterName": "b", "addons": {"ingress-nginx": {"httpsPort": 8443}}}`),
+	}
+	if err := spec.Validate(); err == nil || !strings.Contains(err.Error(), "clusters a and b both use port 0.0.0.0:80/tcp") {
+		t.Errorf("error does not mention the shared port: %v", err)
+	}
+}
+
+func TestCNIInvalid(t *testing.T) {
+	spec := testSpec(t, "docker")
+	spec.CNI = "flannel"
+	spec.Networking.PodSubnet = "10.245.0.0/16"
+	want := `networking.podSubnet must be 10.244.0.0/16 with cni flannel, got "10.245.0.0/16"`
+	if err := spec.Validate(); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("error does not mention %q: %v", want, err)
+	}
+
+	spec.Networking.PodSubnet = "10.244.0.0/16"
+	if err := spec.Validate(); err != nil {
+		t.Errorf("flannel with the default pod subnet: %v", err)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	spec := testSpec(t, "docker")
+	spec.Addons = map[string]addons.Settings{"registry": {Port: 5005}}
+	m, outputs := runDeploy(t, spec)
+
+	want := map[string]any{"registry": map[string]string{
+		"pushUrl":       "localhost:5005",
+		"containerName": "dev-registry",
+		"networkUrl":    "dev-registry:5000",
+	}}
+	if !reflect.DeepEqual(outputs["addons"], want) {
+		t.Errorf("output addons = %v, want %v", outputs["addons"], want)
+	}
+
+	tests := []struct {
+		resource, input string
+		want            []string
+	}{
+		{"create-kind-config", "create", []string{`config_path = "/etc/containerd/certs.d"`}},
+		{"registry-container", "create", []string{"-p 127.0.0.1:5005:5000", `docker network connect kind "$name"`,
+			"kind get nodes --name dev", `'[host."http://dev-registry:5000"]'`, "/etc/containerd/certs.d/localhost:5005/hosts.toml"}},
+		{"registry-container", "delete", []string{"docker rm -f dev-registry", "docker volume rm dev-registry"}},
+		{"wait-for-registry", "create", []string{"docker exec dev-control-plane curl"}},
+	}
+	for _, tt := range tests {
+		script := m.script(t, tt.resource, tt.input)
+		for _, want := range tt.want {
+			if !strings.Contains(script, want) {
+				t.Errorf("%s %s script does not contain %q:\n%s", tt.resource, tt.input, want, script)
+			}
+		}
+	}
+	if !slices.Contains(m.resources["registry-container"].DependsOn, "export-kubeconfig") {
+		t.Errorf("registry-container runs before the kind network exists, depending on %v", m.resources["registry-container"].DependsOn)
+	}
+	if deps := m.resources["wait-for-registry"].DependsOn; !slices.Contains(deps, "registry-container") || !slices.Contains(deps, "addon-registry") {
+		t.Errorf("wait-for-registry: depends on %v, want the container and addon-registry", deps)
+	}
+
+	hosting := m.resources["addon-registry"]
+	if inputs := fmt.Sprint(hosting.Inputs.Mappable()); hosting.Type != "kubernetes:core/v1:ConfigMap" ||
+		!strings.Contains(inputs, "name:local-registry-hosting namespace:kube-public") ||
+		!strings.Contains(inputs, `host: "localhost:5005"`) {
+		t.Errorf("addon-registry: %s %s, want the local-registry-hosting ConfigMap", hosting.Type, inputs)
+	}
+
+	// Every cluster runs a registry of its own, on a port of its own
+	spec = testSpec(t, "docker")
+	spec.Clusters = []json.RawMessage{
+		json.RawMessage(`{"clusterName": "a", "addons": {"registry": {}}}`),
+		json.RawMessage(`{"clusterName": "b", "addons": {"registry": {}}}`),
+	}
+	if err := spec.Validate(); err == nil || !strings.Contains(err.Error(), "clusters a and b both use port 127.0.0.1:5001/tcp") {
+		t.Errorf("error does not mention the shared registry port: %v", err)
+	}
+}
+
+func TestScripts(t *testing.T) {
+	spec := testSpec(t, "vm")
+	spec.VMName = "dev-vm"
+	spec.Autostart = "systemd"
+	m, _ := runDeploy(t, spec)
+
+	tests := []struct {
+		resource, input string
+		want            []string
+	}{
+		{"host-config", "create", []string{"./lima-dev-vm.yaml", "cpus: 8"}},
+		{"host-config", "delete", []string{"rm -f ./lima-dev-vm.yaml"}},
+		{"host", "create", []string{"limactl create --tty=false --name dev-vm ./lima-dev-vm.yaml"}},
+		{"host", "delete", []string{"limactl delete --force dev-vm"}},
+		{"resize-host", "update", []string{"limactl edit --tty=false --cpus 8 --memory 2 --disk 500 dev-vm"}},
+		{"setup-docker", "create", []string{`docker context create "$docker_context"`, "docker_context=lima-dev-vm"}},
+		{"autostart", "create", []string{"systemctl --user enable myk8s-cluster.dev.service"}},
+		{"create-kind-cluster", "create", []string{"cluster_name=dev", `kind create cluster --name "$cluster_name"`}},
+		{"create-kind-cluster", "delete", []string{`kind delete cluster --name "$cluster_name"`}},
+		{"update-shell-profiles", "create", []string{"export KUBECONFIG=", "export DOCKER_CONTEXT=lima-dev-vm"}},
+	}
+	for _, tt := range tests {
+		script := m.script(t, tt.resource, tt.input)
+		for _, want := range tt.want {
+			if !strings.Contains(script, want) {
+				t.Errorf("%s %s script does not contain %q:\n%s", tt.resource, tt.input, want, script)
+			}
+		}
+	}
+
+	// The local daemon needs no context and nothing is torn down with it
+	m, _ = runDeploy(t, testSpec(t, "docker"))
+	if _, ok := m.resources["setup-docker"]; ok {
+		t.Error("docker host registered setup-docker")
+	}
+	if script := m.script(t, "host", "delete"); script != "" {
+		t.Errorf("docker host has a delete script:\n%s", script)
+	}
+	if script := m.script(t, "update-shell-profiles", "create"); strings.Contains(script, "DOCKER_CONTEXT") {
+		t.Errorf("docker host exports DOCKER_CONTEXT:\n%s", script)
+	}
+}
+
+func TestOutputs(t *testing.T) {
+	spec := testSpec(t, "docker")
+	m, outputs := runDeploy(t, spec)
+
+	nodes := []map[string]any{
+		{"name": "dev-control-plane", "ip": "172.18.0.3"},
+		{"name": "dev-worker", "ip": "172.18.0.2"},
+	}
+	want := map[string]any{
+		"clusterName":              "dev",
+		"kubeconfigPath":           filepath.Join(os.Getenv("HOME"), ".kube", "dev-config"),
+		"contextName":              "kind-dev",
+		"kubeconfig":               testKubeconfig,
+		"endpoint":                 "https://127.0.0.1:6443",
+		"certificateAuthorityData": "Y2E=",
+		"nodes":                    nodes,
+		"kubernetesVersion":        "v1.31.2",
+		"dockerHost":               "unix:///var/run/docker.sock",
+		"vmName":                   "",
+		// The checks are off, so the report is empty
+		"healthReport": map[string]any{},
+		"addons":       map[string]any{},
+	}
+	for name, value := range want {
+		if !reflect.DeepEqual(outputs[name], value) {
+			t.Errorf("output %s = %#v, want %#v", name, outputs[name], value)
+		}
+	}
+	clusters, _ := outputs["clusters"].(map[string]any)
+	if dev, _ := clusters["dev"].(map[string]any); len(clusters) != 1 || dev["kubeconfigPath"] != want["kubeconfigPath"] || dev["kubeconfig"] != nil {
+		t.Errorf("output clusters = %v, want dev alone, without its kubeconfig", outputs["clusters"])
+	}
+	if kubeconfigs, _ := outputs["kubeconfigs"].(map[string]any); len(kubeconfigs) != 1 || kubeconfigs["dev"] != testKubeconfig {
+		t.Errorf("output kubeconfigs = %v, want the dev kubeconfig", outputs["kubeconfigs"])
+	}
+	if len(outputs) != len(want)+2 {
+		t.Errorf("outputs = %v, want %v, clusters and kubeconfigs", outputs, want)
+	}
+
+	for name, secret := range m.secrets {
+		if want := name == "kubeconfig" || name == "kubeconfigs"; secret != want {
+			t.Errorf("output %s: secret = %v, want %v", name, secret, want)
+		}
+	}
+}
+
+func TestMergeKubeconfig(t *testing.T) {
+	spec := testSpec(t, "docker")
+	spec.ContextName = "dev"
+	m, _ := runDeploy(t, spec)
+
+	// The program merges the context itself, without kindctl on PATH
+	config, err := os.ReadFile(filepath.Join(os.Getenv("HOME"), ".kube", "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"current-context: dev", "server: https://127.0.0.1:6443"} {
+		if !strings.Contains(string(config), want) {
+			t.Errorf("~/.kube/config does not contain %q:\n%s", want, config)
+		}
+	}
+	record := filepath.Join(os.Getenv("HOME"), ".local", "share", "myk8s-cluster", "kubeconfig", "dev.json")
+	if _, err := os.Stat(record); err != nil {
+		t.Errorf("no record to undo the merge: %v", err)
+	}
+	// Destroying undoes it with what the step keeps, without the program
+	script := m.script(t, "merge-kubeconfig", "delete")
+	for _, want := range []string{"context=dev", "undo users kind-dev user delete-user", "server: https://127.0.0.1:6443", "rm -f \"$record\""} {
+		if !strings.Contains(script, want) {
+			t.Errorf("merge-kubeconfig delete script does not contain %q:\n%s", want, script)
+		}
+	}
+
+	spec = testSpec(t, "docker")
+	spec.MergeKubeconfig = false
+	m, outputs := runDeploy(t, spec)
+	if _, ok := m.resources["merge-kubeconfig"]; ok {
+		t.Error("merge-kubeconfig step with mergeKubeconfig false")
+	}
+	if _, err := os.Stat(filepath.Join(os.Getenv("HOME"), ".kube", "config")); !os.IsNotExist(err) {
+		t.Errorf("~/.kube/config written with mergeKubeconfig false: %v", err)
+	}
+	if outputs["contextName"] != "" {
+		t.Errorf("output contextName = %v, want empty", outputs["contextName"])
+	}
+}
+
+func TestVMName(t *testing.T) {
+	spec := testSpec(t, "vm")
+	spec.VMName = "dev-vm"
+	_, outputs := runDeploy(t, spec)
+	if outputs["vmName"] != "dev-vm" {
+		t.Errorf("output vmName = %v, want dev-vm", outputs["vmName"])
+	}
+}
+
+func TestClusters(t *testing.T) {
+	spec := testSpec(t, "vm")
+	spec.Autostart = "systemd"
+	spec.Clusters = []json.RawMessage{
+		json.RawMessage(`{"clusterName": "dev"}`),
+		json.RawMessage(`{"clusterName": "spoke", "cni": "kindnet", "workers": 1, "kubeconfigPath": "~/spoke.yaml"}`),
+	}
+	m, outputs := runDeploy(t, spec)
+
+	// The host is registered once, by the first cluster
+	for _, name := range []string{"spoke-host", "spoke-autostart", "spoke-setup-docker", "spoke-update-shell-profiles"} {
+		if _, ok := m.resources[name]; ok {
+			t.Errorf("%s is registered for the second cluster", name)
+		}
+	}
+	deps := strings.Join(m.resources["spoke-create-kind-cluster"].DependsOn, " ")
+	for _, dep := range []string{"host", "resize-host", "autostart", "spoke-create-kind-config"} {
+		if !strings.Contains(" "+deps+" ", " "+dep+" ") {
+			t.Errorf("spoke-create-kind-cluster: missing dependency on %s, have %s", dep, deps)
+		}
+	}
+	for step, input := range map[string]string{"autostart": "create", "resize-host": "update"} {
+		if script := m.script(t, step, input); !strings.Contains(script, "for cluster_name in dev spoke; do") {
+			t.Errorf("%s does not start the nodes of both clusters:\n%s", step, script)
+		}
+	}
+	if script := m.script(t, "spoke-create-kind-cluster", "create"); !strings.Contains(script, "--config ./kind-config-spoke.yaml") {
+		t.Errorf("spoke uses the kind config of another cluster:\n%s", script)
+	}
+
+	clusters, _ := outputs["clusters"].(map[string]any)
+	spoke, _ := clusters["spoke"].(map[string]any)
+	if want := filepath.Join(os.Getenv("HOME"), "spoke.yaml"); spoke["kubeconfigPath"] != want {
+		t.Errorf("spoke kubeconfigPath = %v, want %s", spoke["kubeconfigPath"], want)
+	}
+	if outputs["clusterName"] != "dev" || len(clusters) != 2 {
+		t.Errorf("outputs = %v, want dev first and both under clusters", outputs)
+	}
+}
+
+func TestClustersInvalid(t *testing.T) {
+	spec := testSpec(t, "docker")
+	spec.Clusters = []json.RawMessage{
+		json.RawMessage(`{"clusterName": "a", "networking": {"apiServerPort": 6443}}`),
+		json.RawMessage(`{"clusterName": "a", "networking": {"apiServerPort": 6443}}`),
+		json.RawMessage(`{"clusterName": "b", "memory": 4}`),
+		json.RawMessage(`{"clusterName": "c", "workers": -1}`),
+		json.RawMessage(`{"clusterName": "d", "contextName": "kind-a"}`),
+	}
+	err := spec.Validate()
+	if err == nil {
+		t.Fatal("conflicting clusters were accepted")
+	}
+	for _, want := range []string{
+		"clusters[2]: host, vmBackend, autostart, lima, vmName, cpus, memory and disk are shared",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %q: %v", want, err)
+		}
+	}
+
+	spec.Clusters = append(spec.Clusters[:2], spec.Clusters[3:]...)
+	err = spec.Validate()
+	for _, want := range []string{
+		"clusters a and a both use clusterName a",
+		"clusters a and a both use port 127.0.0.1:6443/tcp",
+		"clusters[2] (c):\nworkers must not be negative",
+		"clusters a and d both use contextName kind-a",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %q: %v", want, err)
+		}
+	}
+}
+
+func TestProgramConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cluster, err := json.Marshal(map[string]any{
+		"host":        "docker",
+		"clusterName": "cfg",
+		"cni":         "flannel",
+		"memory":      2,
+		"autostart":   "none",
+		"preflight":   "off",
+		"healthCheck": "off",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := json.Marshal(map[string]string{Name + ":cluster": string(cluster)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(pulumi.EnvConfig, string(config))
+
+	m := &mocks{prefix: "cfg-", resources: map[string]mockResource{}}
+	if err := pulumi.RunErr(Program, pulumi.WithMocks(Name, "test", m)); err != nil {
+		t.Fatal(err)
+	}
+	if files := m.inputs(t, "cni")["files"]; !strings.Contains(fmt.Sprint(files), "kube-flannel.yml") {
+		t.Errorf("cni does not install flannel: files %v", files)
+	}
+}
+
+func TestProgramLegacyConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cluster, err := json.Marshal(map[string]any{
+		"host":        "vm",
+		"lima":        map[string]string{"vmType": "vz"},
+		"clusterName": "cfg",
+		"cni":         "flannel",
+		"memory":      2,
+		"autostart":   "none",
+		"preflight":   "off",
+		"healthCheck": "off",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The object wins for clusterName and memory, and calicoVersion is unused
+	// with flannel
+	config, err := json.Marshal(map[string]string{
+		Name + ":cluster":       string(cluster),
+		Name + ":clusterName":   "legacy",
+		Name + ":memory":        "64",
+		Name + ":calicoVersion": "latest",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(pulumi.EnvConfig, string(config))
+
+	m := &mocks{prefix: "cfg-", resources: map[string]mockResource{}}
+	if err := pulumi.RunErr(Program, pulumi.WithMocks(Name, "test", m)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.resources["cni"]; !ok {
+		t.Errorf("cluster.clusterName did not win over clusterName: have %v", m.names())
+	}
+	if script := m.script(t, "resize-host", "create"); !strings.Contains(script, "memory=2 ") {
+		t.Errorf("cluster.memory did not win over memory:\n%s", script)
+	}
+}
+
+func TestProgramInvalidConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(pulumi.EnvConfig, `{"myk8s-cluster:cluster": "{\"cpus\": 0, \"clusterName\": \"Bad_Name\"}"}`)
+
+	m := &mocks{resources: map[string]mockResource{}}
+	err := pulumi.RunErr(Program, pulumi.WithMocks(Name, "test", m))
+	if err == nil {
+		t.Fatal("invalid config was accepted")
+	}
+	for _, want := range []string{"cpus must be greater than 0", `clusterName "Bad_Name"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %q: %v", want, err)
+		}
+	}
+	if len(m.resources) != 0 {
+		t.Errorf("registered %v before validating", m.names())
+	}
+}
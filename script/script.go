@@ -0,0 +1,60 @@
+// Package script renders the shell scripts run by local.Command from
+// text/template sources. Values are inserted with the quote function so
+// names and paths cannot break out of the argument they are meant for;
+// snippets of shell produced by other scripts are inserted as they are.
+package script
+
+import (
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// Template is a parsed script template.
+type Template struct {
+	tmpl *template.Template
+}
+
+var funcs = template.FuncMap{
+	"quote": Quote,
+	"join":  strings.Join,
+	"quoteAll": func(values []string) string {
+		quoted := make([]string, len(values))
+		for i, v := range values {
+			quoted[i] = Quote(v)
+		}
+		return strings.Join(quoted, " ")
+	},
+}
+
+// New parses text as a script template and panics if it is invalid, like
+// regexp.MustCompile; templates are constants of this program.
+func New(name, text string) *Template {
+	return &Template{tmpl: template.Must(template.New(name).Option("missingkey=error").Funcs(funcs).Parse(text))}
+}
+
+// Render executes the template with data. A failure means the template and
+// its data do not match, which is a bug in this program, so it panics.
+func (t *Template) Render(data any) string {
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, data); err != nil {
+		panic(err)
+	}
+	return b.String()
+}
+
+// Render parses and executes text in one go, for templates used once.
+func Render(name, text string, data any) string {
+	return New(name, text).Render(data)
+}
+
+var safeWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// Quote returns s as a single shell word, leaving words that need no
+// quoting alone to keep scripts readable.
+func Quote(s string) string {
+	if safeWord.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}